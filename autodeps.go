@@ -0,0 +1,117 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DependencyGenerator finds what a file provides and requires, like the file
+// attribute generators of rpmbuild.
+type DependencyGenerator interface {
+	// Generate returns the provides and requires of a regular file. content
+	// reads the body of the file. Files the generator does not handle return
+	// no relations and no error.
+	Generate(f RPMFile, content *io.SectionReader) (provides, requires Relations, err error)
+}
+
+// AddDependencyGenerator adds a generator which runs over every regular file
+// when the package is written. What it finds is added to Provides and
+// Requires.
+func (r *RPM) AddDependencyGenerator(g DependencyGenerator) {
+	r.generators = append(r.generators, g)
+}
+
+// generateDependencies runs the dependency generators over the files, in
+// sorted order.
+func (r *RPM) generateDependencies() error {
+	if len(r.generators) == 0 || r.IsSource {
+		return nil
+	}
+	names := make([]string, 0, len(r.files))
+	for name, f := range r.files {
+		// Hard links are read through the file they link to, and ghost
+		// files have no content.
+		if t := f.Mode & modeTypeMask; t != 0 && t != modeRegular || f.HardLink != "" || f.Type&GhostFile != 0 {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := r.generateFileDependencies(r.files[name]); err != nil {
+			return fmt.Errorf("failed to generate dependencies of %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (r *RPM) generateFileDependencies(f RPMFile) error {
+	content, closer, err := r.readerAt(f)
+	if err != nil {
+		return err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	for _, g := range r.generators {
+		provides, requires, err := g.Generate(f, content)
+		if err != nil {
+			return err
+		}
+		for _, rel := range provides {
+			r.Provides.addIfMissing(rel)
+		}
+		for _, rel := range requires {
+			r.Requires.addIfMissing(rel)
+		}
+	}
+	return nil
+}
+
+// readerAt returns the content of a regular file as an io.ReaderAt, and the
+// source to close once it was read, if it is still open.
+// Sources which can not be read at an offset are copied to a spool, which the
+// payload is then written from, so that they are only read once.
+func (r *RPM) readerAt(f RPMFile) (*io.SectionReader, io.Closer, error) {
+	if f.Source == nil {
+		return io.NewSectionReader(bytes.NewReader(f.Body), 0, int64(len(f.Body))), nil, nil
+	}
+	rc, err := f.Source()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ra, ok := rc.(io.ReaderAt); ok {
+		return io.NewSectionReader(ra, 0, f.size()), rc, nil
+	}
+	defer rc.Close()
+	if r.bodies == nil {
+		r.bodies = newSpool(spoolMemLimit)
+	}
+	off := r.bodies.Len()
+	n, err := io.Copy(r.bodies, rc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n != f.size() {
+		return nil, nil, fmt.Errorf("file content is %d bytes, expected %d", n, f.size())
+	}
+	f.Source = r.bodies.opener(off, n)
+	r.files[f.Name] = f
+	return io.NewSectionReader(r.bodies, off, n), nil, nil
+}
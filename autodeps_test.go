@@ -0,0 +1,188 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// recordingGenerator provides the content of every file it sees.
+type recordingGenerator struct {
+	seen []string
+	err  error
+}
+
+func (g *recordingGenerator) Generate(f RPMFile, content *io.SectionReader) (Relations, Relations, error) {
+	b, err := io.ReadAll(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	g.seen = append(g.seen, f.Name)
+	return Relations{{Name: "content(" + string(b) + ")"}}, Relations{{Name: "bar"}}, g.err
+}
+
+func TestDependencyGenerators(t *testing.T) {
+	b := &bytes.Buffer{}
+	ta := tar.NewWriter(b)
+	for _, h := range []*tar.Header{
+		{Name: "usr/bin/foo", Mode: 0755, Size: 3},
+		{Name: "usr/bin/bar", Typeflag: tar.TypeLink, Linkname: "usr/bin/foo"},
+		{Name: "usr/bin/baz", Typeflag: tar.TypeSymlink, Linkname: "foo"},
+		{Name: "usr/share/foo", Typeflag: tar.TypeDir, Mode: 0755},
+	} {
+		if err := ta.WriteHeader(h); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if h.Size > 0 {
+			if _, err := ta.Write([]byte("foo")); err != nil {
+				t.Fatalf("failed to write body: %v", err)
+			}
+		}
+	}
+	ta.Close()
+	r, err := FromTar(b, RPMMetaData{Name: "foo", Version: "1"})
+	if err != nil {
+		t.Fatalf("FromTar returned err: %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/etc/foo.conf", Mode: 0644, Body: []byte("conf")})
+	r.AddFile(RPMFile{Name: "/var/log/foo.log", Mode: 0644, Type: GhostFile})
+
+	g := &recordingGenerator{}
+	r.AddDependencyGenerator(g)
+	if err := r.Write(io.Discard); err != nil {
+		t.Fatalf("Write returned err: %v", err)
+	}
+	if d := cmp.Diff([]string{"/etc/foo.conf", "/usr/bin/foo"}, g.seen); d != "" {
+		t.Errorf("generated files differ (want->got):\n%v", d)
+	}
+	for _, name := range []string{"content(conf)", "content(foo)", "foo"} {
+		if !containsRelation(r.Provides, name) {
+			t.Errorf("provides %v are missing %s", r.Provides, name)
+		}
+	}
+	if !containsRelation(r.Requires, "bar") {
+		t.Errorf("requires %v are missing bar", r.Requires)
+	}
+}
+
+func TestDependencyGeneratorsOneShotSource(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "foo", Version: "1"})
+	if err != nil {
+		t.Fatalf("NewRPM returned err: %v", err)
+	}
+	calls := 0
+	r.AddFile(RPMFile{
+		Name: "/usr/bin/foo",
+		Mode: 0755,
+		Size: 3,
+		Source: func() (io.ReadCloser, error) {
+			calls++
+			if calls > 1 {
+				return nil, errors.New("source read twice")
+			}
+			// Hide io.ReaderAt, like a pipe or a network stream.
+			return io.NopCloser(struct{ io.Reader }{strings.NewReader("foo")}), nil
+		},
+	})
+	g := &recordingGenerator{}
+	r.AddDependencyGenerator(g)
+	f, err := os.CreateTemp(t.TempDir(), "oneshot-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned err: %v", err)
+	}
+	f.Close()
+	if !containsRelation(r.Provides, "content(foo)") {
+		t.Errorf("provides %v are missing content(foo)", r.Provides)
+	}
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned err: %v", err)
+	}
+	if body := string(got.files["/usr/bin/foo"].Body); body != "foo" {
+		t.Errorf("file content want %q, got %q", "foo", body)
+	}
+}
+
+// countingFile counts how often the file it wraps is closed.
+type countingFile struct {
+	*os.File
+	closes *int
+}
+
+func (f countingFile) Close() error {
+	*f.closes++
+	return f.File.Close()
+}
+
+func TestDependencyGeneratorsFileSource(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "foo")
+	if err := os.WriteFile(name, []byte("foo"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	r, err := NewRPM(RPMMetaData{Name: "foo", Version: "1"})
+	if err != nil {
+		t.Fatalf("NewRPM returned err: %v", err)
+	}
+	opens, closes := 0, 0
+	r.AddFile(RPMFile{
+		Name: "/usr/bin/foo",
+		Mode: 0755,
+		Size: 3,
+		Source: func() (io.ReadCloser, error) {
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			opens++
+			return countingFile{f, &closes}, nil
+		},
+	})
+	g := &recordingGenerator{}
+	r.AddDependencyGenerator(g)
+	if err := r.Write(io.Discard); err != nil {
+		t.Fatalf("Write returned err: %v", err)
+	}
+	if !containsRelation(r.Provides, "content(foo)") {
+		t.Errorf("provides %v are missing content(foo)", r.Provides)
+	}
+	if opens != closes {
+		t.Errorf("source opened %d times, but closed %d times", opens, closes)
+	}
+}
+
+func TestDependencyGeneratorError(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "foo", Version: "1"})
+	if err != nil {
+		t.Fatalf("NewRPM returned err: %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/usr/bin/foo", Mode: 0755, Body: []byte("foo")})
+	wantErr := errors.New("broken")
+	r.AddDependencyGenerator(&recordingGenerator{err: wantErr})
+	if err := r.Write(io.Discard); !errors.Is(err, wantErr) {
+		t.Errorf("Write returned %v, want %v", err, wantErr)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// capsXattr is the PAX record holding the file capabilities in tar files.
+const capsXattr = "SCHILY.xattr.security.capability"
+
+// vfs_cap_data layout, from linux/capability.h.
+const (
+	vfsCapRevisionMask = 0xff000000
+	vfsCapRevision1    = 0x01000000
+	vfsCapRevision2    = 0x02000000
+	vfsCapRevision3    = 0x03000000
+	vfsCapEffective    = 0x000001
+)
+
+// capNames are the names of the capabilities, indexed by number.
+var capNames = []string{
+	"cap_chown",
+	"cap_dac_override",
+	"cap_dac_read_search",
+	"cap_fowner",
+	"cap_fsetid",
+	"cap_kill",
+	"cap_setgid",
+	"cap_setuid",
+	"cap_setpcap",
+	"cap_linux_immutable",
+	"cap_net_bind_service",
+	"cap_net_broadcast",
+	"cap_net_admin",
+	"cap_net_raw",
+	"cap_ipc_lock",
+	"cap_ipc_owner",
+	"cap_sys_module",
+	"cap_sys_rawio",
+	"cap_sys_chroot",
+	"cap_sys_ptrace",
+	"cap_sys_pacct",
+	"cap_sys_admin",
+	"cap_sys_boot",
+	"cap_sys_nice",
+	"cap_sys_resource",
+	"cap_sys_time",
+	"cap_sys_tty_config",
+	"cap_mknod",
+	"cap_lease",
+	"cap_audit_write",
+	"cap_audit_control",
+	"cap_setfcap",
+	"cap_mac_override",
+	"cap_mac_admin",
+	"cap_syslog",
+	"cap_wake_alarm",
+	"cap_block_suspend",
+	"cap_audit_read",
+	"cap_perfmon",
+	"cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+func capName(c int) string {
+	if c < len(capNames) {
+		return capNames[c]
+	}
+	// libcap prints unknown capabilities by number.
+	return strconv.Itoa(c)
+}
+
+// capsFromXattr converts the binary security.capability extended attribute
+// into the text form rpm expects, e.g. `cap_net_admin,cap_net_raw=ep`.
+// Capabilities sharing the same flags are grouped in one clause.
+func capsFromXattr(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", fmt.Errorf("capability xattr too short: %d bytes", len(data))
+	}
+	magic := binary.LittleEndian.Uint32(data)
+	var words int
+	switch magic & vfsCapRevisionMask {
+	case vfsCapRevision1:
+		words = 1
+	case vfsCapRevision2, vfsCapRevision3:
+		// Revision 3 adds the namespace root uid, which rpm can't express.
+		words = 2
+	default:
+		return "", fmt.Errorf("unknown capability xattr revision %#x", magic&vfsCapRevisionMask)
+	}
+	if len(data) < 4+8*words {
+		return "", fmt.Errorf("capability xattr too short: %d bytes", len(data))
+	}
+	var permitted, inheritable uint64
+	for i := 0; i < words; i++ {
+		permitted |= uint64(binary.LittleEndian.Uint32(data[4+8*i:])) << (32 * i)
+		inheritable |= uint64(binary.LittleEndian.Uint32(data[8+8*i:])) << (32 * i)
+	}
+	effective := magic&vfsCapEffective != 0
+
+	var (
+		order  []string
+		groups = map[string][]string{}
+	)
+	for c := 0; c < 64; c++ {
+		var flags string
+		p, i := permitted&(1<<c) != 0, inheritable&(1<<c) != 0
+		if effective && (p || i) {
+			flags += "e"
+		}
+		if i {
+			flags += "i"
+		}
+		if p {
+			flags += "p"
+		}
+		if flags == "" {
+			continue
+		}
+		if _, ok := groups[flags]; !ok {
+			order = append(order, flags)
+		}
+		groups[flags] = append(groups[flags], capName(c))
+	}
+	clauses := make([]string, len(order))
+	for i, flags := range order {
+		clauses[i] = strings.Join(groups[flags], ",") + "=" + flags
+	}
+	return strings.Join(clauses, " "), nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func capsXattrData(magic uint32, words ...uint32) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, magic)
+	for _, w := range words {
+		b = binary.LittleEndian.AppendUint32(b, w)
+	}
+	return b
+}
+
+func TestCapsFromXattr(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{{
+		name: "net raw effective",
+		data: capsXattrData(vfsCapRevision2|vfsCapEffective, 1<<13, 0, 0, 0),
+		want: "cap_net_raw=ep",
+	}, {
+		name: "grouped",
+		data: capsXattrData(vfsCapRevision2|vfsCapEffective, 1<<12|1<<13, 0, 0, 0),
+		want: "cap_net_admin,cap_net_raw=ep",
+	}, {
+		name: "mixed flags",
+		data: capsXattrData(vfsCapRevision2, 1<<10|1<<21, 1<<21, 0, 0),
+		want: "cap_net_bind_service=p cap_sys_admin=ip",
+	}, {
+		name: "high word",
+		data: capsXattrData(vfsCapRevision2, 0, 0, 1<<(38-32)|1<<(50-32), 0),
+		want: "cap_perfmon,50=p",
+	}, {
+		name: "revision 1",
+		data: capsXattrData(vfsCapRevision1|vfsCapEffective, 1<<0, 0),
+		want: "cap_chown=ep",
+	}, {
+		name: "revision 3",
+		data: capsXattrData(vfsCapRevision3|vfsCapEffective, 1<<13, 0, 0, 0, 1000),
+		want: "cap_net_raw=ep",
+	}, {
+		name:    "unknown revision",
+		data:    capsXattrData(0x04000000, 0, 0, 0, 0),
+		wantErr: true,
+	}, {
+		name:    "truncated",
+		data:    capsXattrData(vfsCapRevision2, 0),
+		wantErr: true,
+	}}
+	for _, tc := range testCases {
+		got, err := capsFromXattr(tc.data)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: capsFromXattr returned error %v, want error %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: capsFromXattr = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCapsRoundTrip(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "agent"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/usr/bin/agent", Body: []byte("binary"), Mode: 0755, Capabilities: "cap_net_raw=ep"})
+	r.AddFile(RPMFile{Name: "/usr/bin/helper", Body: []byte("binary"), Mode: 0755})
+	r.AllowListDirs(map[string]bool{})
+
+	f, err := os.CreateTemp(t.TempDir(), "caps-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	capsReq := &Relation{Name: "rpmlib(FileCaps)", Version: "4.6.1-1", Sense: SenseRPMLIB | SenseLess | SenseEqual}
+	found := false
+	for _, req := range r.Requires {
+		found = found || req.Equal(capsReq)
+	}
+	if !found {
+		t.Errorf("Requires %v is missing rpmlib(FileCaps)", r.Requires.String())
+	}
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	if d := cmp.Diff([]string{"cap_net_raw=ep", ""}, got.filecaps); d != "" {
+		t.Errorf("filecaps differ (want->got):\n%v", d)
+	}
+	if c := got.files["/usr/bin/agent"].Capabilities; c != "cap_net_raw=ep" {
+		t.Errorf("/usr/bin/agent capabilities = %q, want cap_net_raw=ep", c)
+	}
+}
+
+func TestNoCapsTag(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/usr/bin/helper", Body: []byte("binary")})
+	if err := r.Write(io.Discard); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	if _, ok := r.headers.entries[tagFileCaps]; ok {
+		t.Errorf("capabilities tag written without capabilities")
+	}
+}
@@ -34,7 +34,12 @@ var (
 	suggests,
 	recommends,
 	requires,
-	conflicts rpmpack.Relations
+	conflicts,
+	orderWithRequires,
+	supplements,
+	enhances rpmpack.Relations
+	verifyRules rpmpack.VerifyRules
+	envRewrites rpmpack.ShebangRewrites
 	name        = flag.String("name", "", "the package name")
 	version     = flag.String("version", "", "the package version")
 	release     = flag.String("release", "", "the rpm release")
@@ -43,6 +48,7 @@ var (
 	prefixes    = flag.String("prefixes", "", "comma separated prefixes for relocatable packages")
 	buildTime   = flag.Int64("build_time", 0, "the build_time unix timestamp")
 	compressor  = flag.String("compressor", "gzip", "the rpm compressor")
+	digest      = flag.String("digest", "sha256", "the file digest algorithm (md5, sha1, sha256, sha384 or sha512)")
 	osName      = flag.String("os", "linux", "the rpm os")
 	summary     = flag.String("summary", "", "the rpm summary")
 	description = flag.String("description", "", "the rpm description")
@@ -52,17 +58,57 @@ var (
 	url         = flag.String("url", "", "the rpm url")
 	licence     = flag.String("licence", "", "the rpm licence name")
 
+	reproducible = flag.Bool("reproducible", false, "make the rpm only depend on its input: build_time is taken from SOURCE_DATE_EPOCH when set, file mtimes are clamped to it and the build host is pinned")
+
 	prein  = flag.String("prein", "", "prein scriptlet contents (not filename)")
 	postin = flag.String("postin", "", "postin scriptlet contents (not filename)")
 	preun  = flag.String("preun", "", "preun scriptlet contents (not filename)")
 	postun = flag.String("postun", "", "postun scriptlet contents (not filename)")
 
+	preinInterpreter  = flag.String("prein_interpreter", "", "prein interpreter and arguments, e.g. \"/usr/bin/bash -e\" or \"<lua>\" (default /bin/sh)")
+	postinInterpreter = flag.String("postin_interpreter", "", "postin interpreter and arguments, e.g. \"/usr/bin/bash -e\" or \"<lua>\" (default /bin/sh)")
+	preunInterpreter  = flag.String("preun_interpreter", "", "preun interpreter and arguments, e.g. \"/usr/bin/bash -e\" or \"<lua>\" (default /bin/sh)")
+	postunInterpreter = flag.String("postun_interpreter", "", "postun interpreter and arguments, e.g. \"/usr/bin/bash -e\" or \"<lua>\" (default /bin/sh)")
+
+	verifyScript            = flag.String("verifyscript", "", "verifyscript contents (not filename), run by rpm -V")
+	verifyScriptInterpreter = flag.String("verifyscript_interpreter", "", "verifyscript interpreter and arguments (default /bin/sh)")
+
 	useDirAllowlist  = flag.Bool("use_dir_allowlist", false, "Only include dirs in the explicit allow list")
 	dirAllowlistFile = flag.String("dir_allowlist_file", "", "A file with one directory per line to include from the tar to the rpm")
+	parentDirs       = flag.String("parent_dirs", "", "comma separated roots under which missing parent directories are added, owned by root with mode 0755 (eg. /opt/ourco)")
 
 	outputfile = flag.String("file", "", "write rpm to `FILE` instead of stdout")
+
+	manifestFile = flag.String("manifest", "", "read the package metadata, scriptlets, triggers and file attributes from a YAML or JSON `MANIFEST` instead of flags")
+	specFile     = flag.String("spec", "", "read the package from a spec `FILE` instead of flags, with the tar as its buildroot")
+
+	elfDeps        = flag.Bool("elf_deps", false, "add the shared libraries ELF files need to requires, and the ones they are to provides")
+	shebangDeps    = flag.Bool("shebang_deps", false, "add the interpreters of executable scripts, from their #! line, to requires")
+	shebangExclude = flag.String("shebang_exclude", "", "comma separated interpreters which are not required by -shebang_deps, as globs (eg. /bin/sh,/usr/bin/env)")
+	pythonDeps     = flag.Bool("python_deps", false, "add the python3dist() of *.dist-info/METADATA files to provides, and their Requires-Dist to requires")
+	pkgConfigDeps  = flag.Bool("pkgconfig_deps", false, "add the pkgconfig() of */pkgconfig/*.pc files to provides, and their Requires to requires")
+	goDeps         = flag.Bool("go_deps", false, "add the modules Go executables were built with to provides, as bundled(golang(module)) = version")
+	goToolchain    = flag.Bool("go_deps_toolchain", false, "also add the Go toolchain to the provides of -go_deps, as bundled(golang) = version")
+	goSkipReplaced = flag.Bool("go_deps_skip_replaced", false, "leave the replaced modules out of the provides of -go_deps")
 )
 
+// qualifiedRequires is a flag adding requirements with a qualifier, e.g.
+// `-requires_post systemd` is `Requires(post): systemd`.
+type qualifiedRequires string
+
+func (q qualifiedRequires) String() string {
+	return ""
+}
+
+func (q qualifiedRequires) Set(value string) error {
+	_, rels, err := rpmpack.ParseDependencies("Requires(" + string(q) + "): " + value)
+	if err != nil {
+		return err
+	}
+	requires = append(requires, rels...)
+	return nil
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr,
 		`Usage:
@@ -79,9 +125,66 @@ func main() {
 	flag.Var(&recommends, "recommends", "rpm recommends values, can be just name or in the form of name=version (eg. bla=1.2.3)")
 	flag.Var(&requires, "requires", "rpm requires values, can be just name or in the form of name=version (eg. bla=1.2.3)")
 	flag.Var(&conflicts, "conflicts", "rpm provides values, can be just name or in the form of name=version (eg. bla=1.2.3)")
+	flag.Var(&orderWithRequires, "order_with_requires", "rpm order with requires values, packages installed first when present, in the same form as requires")
+	flag.Var(&supplements, "supplements", "rpm supplements values, reverse recommends, in the same form as requires")
+	flag.Var(&enhances, "enhances", "rpm enhances values, reverse suggests, in the same form as requires")
+	for _, q := range []string{"pre", "post", "preun", "postun", "pretrans", "posttrans"} {
+		flag.Var(qualifiedRequires(q), "requires_"+q, "rpm requires values needed by the "+q+" scriptlet, like Requires("+q+"), in the same form as requires")
+	}
+	flag.Var(&envRewrites, "shebang_env_rewrite", "what -shebang_deps requires for a program run through env, in the form of NAME=REQUIREMENT (eg. python3=/usr/bin/python3), instead of its name")
+	flag.Var(&verifyRules, "verify", "checks of rpm -V for files matching a glob, in the form of GLOB=VERIFY like %verify (eg. '/var/log/*=not md5 size mtime'), the last matching rule wins")
 	flag.Usage = usage
 	flag.Parse()
-	if *name == "" || *version == "" {
+	// Flags which can be combined with -manifest and -spec.
+	packageFlags := map[string]bool{
+		"file":                  true,
+		"elf_deps":              true,
+		"shebang_deps":          true,
+		"shebang_exclude":       true,
+		"shebang_env_rewrite":   true,
+		"python_deps":           true,
+		"pkgconfig_deps":        true,
+		"go_deps":               true,
+		"go_deps_toolchain":     true,
+		"go_deps_skip_replaced": true,
+	}
+	var (
+		manifest *rpmpack.Manifest
+		spec     *rpmpack.Spec
+	)
+	if *specFile != "" {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name != "spec" && !packageFlags[f.Name] {
+				fmt.Fprintf(os.Stderr, "-%s can't be combined with -spec\n", f.Name)
+				os.Exit(2)
+			}
+		})
+		f, err := os.Open(*specFile)
+		if err != nil {
+			log.Fatalf("Failed to read spec %s: %v", *specFile, err)
+		}
+		spec, err = rpmpack.ParseSpec(f, nil)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *specFile, err)
+			os.Exit(2)
+		}
+	} else if *manifestFile != "" {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name != "manifest" && !packageFlags[f.Name] {
+				fmt.Fprintf(os.Stderr, "-%s can't be combined with -manifest\n", f.Name)
+				os.Exit(2)
+			}
+		})
+		data, err := os.ReadFile(*manifestFile)
+		if err != nil {
+			log.Fatalf("Failed to read manifest %s: %v", *manifestFile, err)
+		}
+		if manifest, err = rpmpack.ParseManifest(data); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *manifestFile, err)
+			os.Exit(2)
+		}
+	} else if *name == "" || *version == "" {
 		fmt.Fprintln(os.Stderr, "name and version are required")
 		flag.Usage()
 		os.Exit(2)
@@ -123,36 +226,69 @@ func main() {
 		defer f.Close()
 		w = f
 	}
-	r, err := rpmpack.FromTar(
-		i,
-		rpmpack.RPMMetaData{
-			Name:        *name,
-			Version:     *version,
-			Release:     *release,
-			Epoch:       uint32(*epoch),
-			BuildTime:   buildTimeStamp,
-			Prefixes:    strings.Split(*prefixes, ","),
-			Arch:        *arch,
-			OS:          *osName,
-			Vendor:      *vendor,
-			Packager:    *packager,
-			Group:       *group,
-			URL:         *url,
-			Licence:     *licence,
-			Description: *description,
-			Summary:     *summary,
-			Compressor:  *compressor,
-			Provides:    provides,
-			Obsoletes:   obsoletes,
-			Suggests:    suggests,
-			Recommends:  recommends,
-			Requires:    requires,
-			Conflicts:   conflicts,
-		})
+	md := rpmpack.RPMMetaData{
+		Name:            *name,
+		Version:         *version,
+		Release:         *release,
+		Epoch:           uint32(*epoch),
+		BuildTime:       buildTimeStamp,
+		Prefixes:        strings.Split(*prefixes, ","),
+		Arch:            *arch,
+		OS:              *osName,
+		Vendor:          *vendor,
+		Packager:        *packager,
+		Group:           *group,
+		URL:             *url,
+		Licence:         *licence,
+		Description:     *description,
+		Summary:         *summary,
+		Compressor:      *compressor,
+		DigestAlgorithm: *digest,
+		Reproducible:    *reproducible,
+		Provides:        provides,
+		Obsoletes:       obsoletes,
+		Suggests:        suggests,
+		Recommends:      recommends,
+		Requires:        requires,
+		Conflicts:       conflicts,
+
+		OrderWithRequires: orderWithRequires,
+		Supplements:       supplements,
+		Enhances:          enhances,
+	}
+	if manifest != nil {
+		md = manifest.Metadata()
+	}
+	var r *rpmpack.RPM
+	var err error
+	if spec != nil {
+		r, err = spec.FromTar(i)
+	} else {
+		r, err = rpmpack.FromTar(i, md)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "tar2rpm error: %v\n", err)
 		os.Exit(1)
 	}
+	if *elfDeps {
+		r.AddDependencyGenerator(rpmpack.ELFDependencies{})
+	}
+	if *shebangDeps {
+		g := rpmpack.ShebangDependencies{EnvRewrites: envRewrites}
+		if *shebangExclude != "" {
+			g.Exclude = strings.Split(*shebangExclude, ",")
+		}
+		r.AddDependencyGenerator(g)
+	}
+	if *pythonDeps {
+		r.AddDependencyGenerator(rpmpack.PythonDistDependencies{})
+	}
+	if *pkgConfigDeps {
+		r.AddDependencyGenerator(rpmpack.PkgConfigDependencies{})
+	}
+	if *goDeps {
+		r.AddDependencyGenerator(rpmpack.GoBuildInfoDependencies{Toolchain: *goToolchain, SkipReplaced: *goSkipReplaced})
+	}
 	if *useDirAllowlist {
 		al := map[string]bool{}
 		if *dirAllowlistFile != "" {
@@ -169,11 +305,29 @@ func main() {
 		}
 		r.AllowListDirs(al)
 	}
+	if *parentDirs != "" {
+		r.SetParentDirs(rpmpack.ParentDirs{Roots: strings.Split(*parentDirs, ",")})
+	}
 
-	r.AddPrein(*prein)
-	r.AddPostin(*postin)
-	r.AddPreun(*preun)
-	r.AddPostun(*postun)
+	if spec == nil {
+		r.AddPrein(*prein, strings.Fields(*preinInterpreter)...)
+		r.AddPostin(*postin, strings.Fields(*postinInterpreter)...)
+		r.AddPreun(*preun, strings.Fields(*preunInterpreter)...)
+		r.AddPostun(*postun, strings.Fields(*postunInterpreter)...)
+		r.AddVerifyScript(*verifyScript, strings.Fields(*verifyScriptInterpreter)...)
+	}
+
+	if manifest != nil {
+		if err := manifest.Apply(r); err != nil {
+			fmt.Fprintf(os.Stderr, "tar2rpm error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := r.ApplyVerifyRules(verifyRules); err != nil {
+		fmt.Fprintf(os.Stderr, "tar2rpm error: %v\n", err)
+		os.Exit(1)
+	}
 
 	if err := r.Write(w); err != nil {
 		fmt.Fprintf(os.Stderr, "rpm write error: %v\n", err)
@@ -0,0 +1,176 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/cavaliergopher/cpio"
+)
+
+// The payload is a newc cpio archive. The newc format stores sizes as 8 hex
+// digits, so it cannot hold files of 4GiB or more. For such packages rpm uses
+// a "stripped" variant, where each entry only holds the index of the file in
+// the header, and all other metadata comes from the header.
+// https://github.com/rpm-software-management/rpm/blob/master/lib/cpio.c
+const (
+	strippedMagic      = "07070X"
+	strippedHeaderSize = 14
+	newcMagic          = "070701"
+	newcHeaderSize     = 110
+	cpioTrailer        = "TRAILER!!!"
+)
+
+// cpioEntry is the header of one entry of the payload.
+type cpioEntry struct {
+	name string
+	// fx is the index of the file in the rpm header.
+	fx        int
+	inode     int32
+	mode      uint
+	links     int
+	mtime     uint32
+	size      int64
+	rdevMajor uint32
+	rdevMinor uint32
+}
+
+// cpioWriter writes the payload archive, either in newc or in stripped format.
+type cpioWriter struct {
+	w         io.Writer
+	stripped  bool
+	offset    int64
+	remaining int64
+}
+
+func newCpioWriter(w io.Writer, stripped bool) *cpioWriter {
+	return &cpioWriter{w: w, stripped: stripped}
+}
+
+func (c *cpioWriter) write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.offset += int64(n)
+	return n, err
+}
+
+func (c *cpioWriter) pad() error {
+	_, err := c.write(make([]byte, (4-c.offset%4)%4))
+	return err
+}
+
+// WriteHeader starts a new entry, which will be followed by e.size bytes of
+// content.
+func (c *cpioWriter) WriteHeader(e cpioEntry) error {
+	if c.remaining != 0 {
+		return fmt.Errorf("%d bytes missing from previous entry", c.remaining)
+	}
+	if err := c.pad(); err != nil {
+		return err
+	}
+	if c.stripped {
+		if _, err := c.write([]byte(fmt.Sprintf("%s%08x", strippedMagic, e.fx))); err != nil {
+			return err
+		}
+	} else if err := c.writeNewc(e); err != nil {
+		return err
+	}
+	c.remaining = e.size
+	return nil
+}
+
+func (c *cpioWriter) writeNewc(e cpioEntry) error {
+	if e.size > math.MaxUint32 {
+		return fmt.Errorf("%s is too large for a newc cpio archive", e.name)
+	}
+	fields := []int64{
+		int64(e.inode), int64(e.mode), 0, 0, int64(e.links), int64(e.mtime), e.size,
+		0, 0, int64(e.rdevMajor), int64(e.rdevMinor), int64(len(e.name) + 1), 0,
+	}
+	hdr := &bytes.Buffer{}
+	hdr.WriteString(newcMagic)
+	for _, f := range fields {
+		fmt.Fprintf(hdr, "%08x", f)
+	}
+	hdr.WriteString(e.name)
+	hdr.WriteByte(0)
+	if _, err := c.write(hdr.Bytes()); err != nil {
+		return err
+	}
+	return c.pad()
+}
+
+// Write writes content of the current entry.
+func (c *cpioWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > c.remaining {
+		return 0, cpio.ErrWriteTooLong
+	}
+	n, err := c.write(p)
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// Close writes the trailer. Like rpm, the trailer is always a newc entry.
+func (c *cpioWriter) Close() error {
+	if c.remaining != 0 {
+		return fmt.Errorf("%d bytes missing from last entry", c.remaining)
+	}
+	if err := c.pad(); err != nil {
+		return err
+	}
+	if err := c.writeNewc(cpioEntry{name: cpioTrailer, links: 1}); err != nil {
+		return err
+	}
+	return c.pad()
+}
+
+// readStripped iterates over a stripped cpio archive, calling fn with the file
+// index and content of each entry. sizeOf returns the size of the content of
+// the file with the given index, which is not part of the archive.
+func readStripped(data []byte, sizeOf func(fx int) (int64, error), fn func(fx int, body []byte) error) error {
+	offset := 0
+	for {
+		offset += (4 - offset%4) % 4
+		if len(data) < offset+len(newcMagic) {
+			return fmt.Errorf("stripped cpio archive truncated at %d", offset)
+		}
+		if string(data[offset:offset+len(newcMagic)]) == newcMagic {
+			// Only the trailer is stored in newc format.
+			return nil
+		}
+		if len(data) < offset+strippedHeaderSize || string(data[offset:offset+len(strippedMagic)]) != strippedMagic {
+			return fmt.Errorf("bad stripped cpio header at %d", offset)
+		}
+		fx, err := strconv.ParseUint(string(data[offset+len(strippedMagic):offset+strippedHeaderSize]), 16, 32)
+		if err != nil {
+			return fmt.Errorf("bad stripped cpio file index at %d: %w", offset, err)
+		}
+		offset += strippedHeaderSize
+		size, err := sizeOf(int(fx))
+		if err != nil {
+			return err
+		}
+		if int64(len(data)-offset) < size {
+			return fmt.Errorf("stripped cpio entry %d truncated", fx)
+		}
+		if err := fn(int(fx), data[offset:offset+int(size)]); err != nil {
+			return err
+		}
+		offset += int(size)
+	}
+}
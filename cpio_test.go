@@ -0,0 +1,131 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/cavaliergopher/cpio"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStrippedWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := newCpioWriter(b, true)
+	if err := w.WriteHeader(cpioEntry{fx: 0, size: 3}); err != nil {
+		t.Fatalf("WriteHeader returned error %v", err)
+	}
+	if _, err := w.Write([]byte("abc")); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	if err := w.WriteHeader(cpioEntry{fx: 2}); err != nil {
+		t.Fatalf("WriteHeader returned error %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error %v", err)
+	}
+
+	want := "07070X00000000" + "abc" + "\x00\x00\x00" +
+		"07070X00000002" + "\x00\x00" +
+		"070701" + "00000000000000000000000000000000" + "00000001" +
+		"000000000000000000000000000000000000000000000000" + "0000000b" + "00000000" +
+		"TRAILER!!!\x00" + "\x00\x00\x00"
+	if d := cmp.Diff(want, b.String()); d != "" {
+		t.Errorf("stripped archive differs (want->got):\n%q", d)
+	}
+
+	sizes := map[int]int64{0: 3, 2: 0}
+	got := map[int]string{}
+	err := readStripped(b.Bytes(), func(fx int) (int64, error) {
+		size, ok := sizes[fx]
+		if !ok {
+			return 0, fmt.Errorf("unexpected index %d", fx)
+		}
+		return size, nil
+	}, func(fx int, body []byte) error {
+		got[fx] = string(body)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readStripped returned error %v", err)
+	}
+	if d := cmp.Diff(map[int]string{0: "abc", 2: ""}, got); d != "" {
+		t.Errorf("readStripped differs (want->got):\n%v", d)
+	}
+}
+
+func TestCpioWriterTooLong(t *testing.T) {
+	w := newCpioWriter(&bytes.Buffer{}, false)
+	if err := w.WriteHeader(cpioEntry{name: "a", size: 1}); err != nil {
+		t.Fatalf("WriteHeader returned error %v", err)
+	}
+	if _, err := w.Write([]byte("ab")); err == nil {
+		t.Error("Write should fail when writing more than the entry size")
+	}
+}
+
+func TestNewcWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := newCpioWriter(b, false)
+	if err := w.WriteHeader(cpioEntry{name: "/dev/null", inode: 7, mode: 020666, links: 1, rdevMajor: 1, rdevMinor: 3}); err != nil {
+		t.Fatalf("WriteHeader returned error %v", err)
+	}
+	if err := w.WriteHeader(cpioEntry{name: "/etc/motd", inode: 8, mode: 0100644, links: 1, mtime: 5, size: 2}); err != nil {
+		t.Fatalf("WriteHeader returned error %v", err)
+	}
+	if _, err := w.Write([]byte("hi")); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error %v", err)
+	}
+
+	if got, want := string(b.Bytes()[78:94]), "0000000100000003"; got != want {
+		t.Errorf("rdev fields = %s, want %s", got, want)
+	}
+
+	c := cpio.NewReader(b)
+	type entry struct {
+		Name  string
+		Inode int64
+		Mode  cpio.FileMode
+		Size  int64
+		Body  string
+	}
+	got := []entry{}
+	for {
+		h, err := c.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+		body, err := io.ReadAll(c)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+		got = append(got, entry{h.Name, h.Inode, h.Mode, h.Size, string(body)})
+	}
+	want := []entry{
+		{"/dev/null", 7, 020666, 0, ""},
+		{"/etc/motd", 8, 0100644, 2, "hi"},
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("archive differs (want->got):\n%v", d)
+	}
+}
@@ -1,6 +1,62 @@
+# The attributes describing the package, which a manifest replaces.
+_PACKAGE_ATTRS = [
+    "pkg_name",
+    "version",
+    "release",
+    "arch",
+    "packager",
+    "epoch",
+    "prein",
+    "postin",
+    "preun",
+    "postun",
+    "requires",
+    "prefixes",
+    "build_time",
+    "use_dir_allowlist",
+    "dir_allowlist_file",
+]
+
 def _pkg_tar2rpm_impl(ctx):
+    if ctx.file.manifest:
+        conflicting = [name for name in _PACKAGE_ATTRS if getattr(ctx.attr, name)]
+        if conflicting:
+            fail("manifest can not be combined with " + ", ".join(conflicting))
+    elif not ctx.attr.pkg_name or not ctx.attr.version:
+        fail("pkg_name and version are required unless a manifest is set")
     files = [ctx.file.data]
     args = ctx.actions.args()
+    if ctx.attr.elf_deps:
+        args.add("--elf_deps")
+    if ctx.attr.shebang_deps:
+        args.add("--shebang_deps")
+        args.add_joined("--shebang_exclude", ctx.attr.shebang_exclude, join_with = ",", omit_if_empty = True)
+        for name, req in ctx.attr.shebang_env_rewrites.items():
+            args.add("--shebang_env_rewrite", name + "=" + req)
+    if ctx.attr.python_deps:
+        args.add("--python_deps")
+    if ctx.attr.pkgconfig_deps:
+        args.add("--pkgconfig_deps")
+    if ctx.attr.go_deps:
+        args.add("--go_deps")
+        if ctx.attr.go_deps_toolchain:
+            args.add("--go_deps_toolchain")
+        if ctx.attr.go_deps_skip_replaced:
+            args.add("--go_deps_skip_replaced")
+    if ctx.file.manifest:
+        # The manifest replaces every other flag describing the package.
+        args.add("--manifest", ctx.file.manifest)
+        files.append(ctx.file.manifest)
+        args.add("--file", ctx.outputs.out)
+        args.add(ctx.file.data)
+        ctx.actions.run(
+            executable = ctx.executable.tar2rpm,
+            arguments = [args],
+            inputs = files,
+            outputs = [ctx.outputs.out],
+            mnemonic = "tar2rpm",
+        )
+        return
     args.add("--name", ctx.attr.pkg_name)
     args.add("--version", ctx.attr.version)
     args.add("--release", ctx.attr.release)
@@ -35,8 +91,11 @@ pkg_tar2rpm = rule(
     implementation = _pkg_tar2rpm_impl,
     attrs = {
         "data": attr.label(mandatory = True, allow_single_file = [".tar"]),
-        "pkg_name": attr.string(mandatory = True),
-        "version": attr.string(mandatory = True),
+        "manifest": attr.label(allow_single_file = [".yaml", ".yml", ".json"], doc = """A YAML or JSON
+package manifest. When set, it describes the whole package and can not be combined with the
+attributes describing it, like pkg_name or requires."""),
+        "pkg_name": attr.string(doc = "Required unless a manifest is set."),
+        "version": attr.string(doc = "Required unless a manifest is set."),
         "release": attr.string(),
         "arch": attr.string(),
         "packager": attr.string(),
@@ -48,6 +107,15 @@ pkg_tar2rpm = rule(
         "requires": attr.string_list(),
         "prefixes": attr.string_list(),
         "build_time": attr.string(),
+        "elf_deps": attr.bool(default = False, doc = "Add the shared libraries of ELF files to requires and provides."),
+        "shebang_deps": attr.bool(default = False, doc = "Add the interpreters of executable scripts to requires."),
+        "shebang_exclude": attr.string_list(doc = "Interpreters shebang_deps does not require, as globs."),
+        "shebang_env_rewrites": attr.string_dict(doc = "What shebang_deps requires for programs run through env, instead of their name."),
+        "python_deps": attr.bool(default = False, doc = "Add the python3dist() of Python packages to provides, and their Requires-Dist to requires."),
+        "pkgconfig_deps": attr.bool(default = False, doc = "Add the pkgconfig() of pkg-config files to provides, and their Requires to requires."),
+        "go_deps": attr.bool(default = False, doc = "Add the modules Go executables were built with to provides, as bundled(golang(module))."),
+        "go_deps_toolchain": attr.bool(default = False, doc = "Also add the Go toolchain to the provides of go_deps, as bundled(golang)."),
+        "go_deps_skip_replaced": attr.bool(default = False, doc = "Leave the replaced modules out of the provides of go_deps."),
         "use_dir_allowlist": attr.bool(default = False, doc = """Only include
 directories themselves if they are in the allowlist file. Using this without an allowlist means do not include directories at all, only files."""),
         "dir_allowlist_file": attr.label(allow_single_file = True, doc = "A file with a list of directories to include in the rpm. The files contained in the directories are always added."),
@@ -0,0 +1,189 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// dependencyTags are the dependency tags of spec files, keyed by their
+// lowercase name since rpmbuild ignores their case.
+var dependencyTags = map[string]string{
+	"provides":          "Provides",
+	"obsoletes":         "Obsoletes",
+	"suggests":          "Suggests",
+	"recommends":        "Recommends",
+	"requires":          "Requires",
+	"conflicts":         "Conflicts",
+	"orderwithrequires": "OrderWithRequires",
+	"supplements":       "Supplements",
+	"enhances":          "Enhances",
+}
+
+// qualifierSense maps the qualifiers of Requires(...) to their sense bits.
+var qualifierSense = map[string]rpmSense{
+	"pre":       ScriptPre,
+	"post":      ScriptPost,
+	"preun":     ScriptPreun,
+	"postun":    ScriptPostun,
+	"pretrans":  PreTrans,
+	"posttrans": PostTrans,
+	"verify":    ScriptVerify,
+	"interp":    Interp,
+}
+
+// ParseQualifiers parses the comma separated qualifiers of a requirement,
+// e.g. `pre,postun`, into their sense bits.
+func ParseQualifiers(q string) (rpmSense, error) {
+	var sense rpmSense
+	for _, name := range strings.Split(q, ",") {
+		s, ok := qualifierSense[strings.TrimSpace(name)]
+		if !ok {
+			return SenseAny, fmt.Errorf("unknown dependency qualifier: %q", name)
+		}
+		sense |= s
+	}
+	return sense, nil
+}
+
+// ParseDependencies parses a dependency line of a spec file, like
+// `Requires(post): systemd, coreutils >= 8.0`. It returns the name of the
+// dependency tag, e.g. `Requires`, and the relations, with the qualifier bits
+// set in their Sense. Only Requires takes qualifiers.
+func ParseDependencies(line string) (string, Relations, error) {
+	head, deps, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, fmt.Errorf("missing ':' in dependency %q", line)
+	}
+	head = strings.TrimSpace(head)
+	var qualifiers rpmSense
+	if name, q, ok := strings.Cut(head, "("); ok {
+		q, ok = strings.CutSuffix(q, ")")
+		if !ok {
+			return "", nil, fmt.Errorf("unterminated qualifier in dependency %q", line)
+		}
+		var err error
+		if qualifiers, err = ParseQualifiers(q); err != nil {
+			return "", nil, err
+		}
+		head = name
+	}
+	tag, ok := dependencyTags[strings.ToLower(head)]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown dependency tag: %q", head)
+	}
+	if qualifiers != SenseAny && tag != "Requires" {
+		return "", nil, fmt.Errorf("%s does not take qualifiers", tag)
+	}
+	related, err := splitRelations(deps)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(related) == 0 {
+		return "", nil, fmt.Errorf("no dependencies in %q", line)
+	}
+	var rels Relations
+	for _, s := range related {
+		rel, err := NewRelation(s)
+		if err != nil {
+			return "", nil, err
+		}
+		rel.Sense |= qualifiers
+		rels.addIfMissing(rel)
+	}
+	return tag, rels, nil
+}
+
+// splitRelations splits a list of dependencies, separated by commas or
+// whitespace, into strings for NewRelation. Parentheses group their content,
+// so rich dependencies and names like `perl(Foo::Bar)` stay whole.
+func splitRelations(s string) ([]string, error) {
+	var (
+		tokens []string
+		cur    strings.Builder
+		depth  int
+	)
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, c := range s {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in %q", s)
+			}
+		case depth == 0 && (c == ',' || unicode.IsSpace(c)):
+			flush()
+			continue
+		}
+		cur.WriteRune(c)
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in %q", s)
+	}
+	flush()
+
+	// Join the operator and version to their name: `foo >= 1`, `foo >=1` and
+	// `foo>= 1` are all one relation.
+	isOp := func(c byte) bool { return strings.IndexByte("<>=", c) >= 0 }
+	var related []string
+	for i := 0; i < len(tokens); i++ {
+		rel := tokens[i]
+		if i+1 < len(tokens) && isOp(tokens[i+1][0]) {
+			i++
+			rel += " " + tokens[i]
+		}
+		if isOp(rel[len(rel)-1]) {
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("missing version in %q", s)
+			}
+			i++
+			rel += " " + tokens[i]
+		}
+		related = append(related, rel)
+	}
+	return related, nil
+}
+
+// AddDependencies parses a dependency line with ParseDependencies and adds
+// the relations to the matching category.
+func (m *RPMMetaData) AddDependencies(line string) error {
+	tag, rels, err := ParseDependencies(line)
+	if err != nil {
+		return err
+	}
+	category := map[string]*Relations{
+		"Provides":          &m.Provides,
+		"Obsoletes":         &m.Obsoletes,
+		"Suggests":          &m.Suggests,
+		"Recommends":        &m.Recommends,
+		"Requires":          &m.Requires,
+		"Conflicts":         &m.Conflicts,
+		"OrderWithRequires": &m.OrderWithRequires,
+		"Supplements":       &m.Supplements,
+		"Enhances":          &m.Enhances,
+	}[tag]
+	for _, rel := range rels {
+		category.addIfMissing(rel)
+	}
+	return nil
+}
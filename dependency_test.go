@@ -0,0 +1,156 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDependencies(t *testing.T) {
+	testCases := []struct {
+		name    string
+		line    string
+		wantTag string
+		want    Relations
+		wantErr bool
+	}{{
+		name:    "plain",
+		line:    "Requires: bash",
+		wantTag: "Requires",
+		want:    Relations{{Name: "bash"}},
+	}, {
+		name:    "qualifier",
+		line:    "Requires(post): systemd >= 240",
+		wantTag: "Requires",
+		want:    Relations{{Name: "systemd", Version: "240", Sense: SenseGreater | SenseEqual | ScriptPost}},
+	}, {
+		name:    "several qualifiers",
+		line:    "Requires(pre,postun): shadow-utils",
+		wantTag: "Requires",
+		want:    Relations{{Name: "shadow-utils", Sense: ScriptPre | ScriptPostun}},
+	}, {
+		name:    "list",
+		line:    "requires: a, b >=1 c>= 2,d<3 perl(Foo::Bar) = 1.0 (e or f)",
+		wantTag: "Requires",
+		want: Relations{
+			{Name: "a"},
+			{Name: "b", Version: "1", Sense: SenseGreater | SenseEqual},
+			{Name: "c", Version: "2", Sense: SenseGreater | SenseEqual},
+			{Name: "d", Version: "3", Sense: SenseLess},
+			{Name: "perl(Foo::Bar)", Version: "1.0", Sense: SenseEqual},
+			{Name: "(e or f)"},
+		},
+	}, {
+		name:    "new categories",
+		line:    "OrderWithRequires: systemd",
+		wantTag: "OrderWithRequires",
+		want:    Relations{{Name: "systemd"}},
+	}, {
+		name:    "missing colon",
+		line:    "Requires bash",
+		wantErr: true,
+	}, {
+		name:    "unknown tag",
+		line:    "Depends: bash",
+		wantErr: true,
+	}, {
+		name:    "unknown qualifier",
+		line:    "Requires(install): bash",
+		wantErr: true,
+	}, {
+		name:    "qualifier on provides",
+		line:    "Provides(post): bash",
+		wantErr: true,
+	}, {
+		name:    "missing version",
+		line:    "Requires: bash >=",
+		wantErr: true,
+	}, {
+		name:    "unbalanced",
+		line:    "Requires: (a or b",
+		wantErr: true,
+	}, {
+		name:    "empty",
+		line:    "Requires:",
+		wantErr: true,
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tag, rels, err := ParseDependencies(tc.line)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ParseDependencies(%q) returned no error", tc.line)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDependencies(%q) returned err: %v", tc.line, err)
+			}
+			if tag != tc.wantTag {
+				t.Errorf("ParseDependencies(%q) tag want %q, got %q", tc.line, tc.wantTag, tag)
+			}
+			if d := cmp.Diff(tc.want, rels); d != "" {
+				t.Errorf("ParseDependencies(%q) relations differ (want->got):\n%v", tc.line, d)
+			}
+		})
+	}
+}
+
+func TestDependencyCategoriesRoundTrip(t *testing.T) {
+	md := RPMMetaData{Name: "test", Version: "1.0"}
+	for _, line := range []string{
+		"Requires(post): systemd",
+		"OrderWithRequires: systemd",
+		"Supplements: (foo and bar)",
+		"Enhances: baz > 1",
+	} {
+		if err := md.AddDependencies(line); err != nil {
+			t.Fatalf("AddDependencies(%q) returned err: %v", line, err)
+		}
+	}
+	r, err := NewRPM(md)
+	if err != nil {
+		t.Fatalf("NewRPM returned err: %v", err)
+	}
+	f, err := os.CreateTemp(t.TempDir(), "dependency-*.rpm")
+	if err != nil {
+		t.Fatalf("CreateTemp returned err: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned err: %v", err)
+	}
+	f.Close()
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned err: %v", err)
+	}
+	if d := cmp.Diff(&Relation{Name: "systemd", Sense: ScriptPost}, got.Requires[0]); d != "" {
+		t.Errorf("Requires differs (want->got):\n%v", d)
+	}
+	if d := cmp.Diff(Relations{{Name: "systemd"}}, got.OrderWithRequires); d != "" {
+		t.Errorf("OrderWithRequires differs (want->got):\n%v", d)
+	}
+	if d := cmp.Diff(Relations{{Name: "(foo and bar)"}}, got.Supplements); d != "" {
+		t.Errorf("Supplements differs (want->got):\n%v", d)
+	}
+	if d := cmp.Diff(Relations{{Name: "baz", Version: "1", Sense: SenseGreater}}, got.Enhances); d != "" {
+		t.Errorf("Enhances differs (want->got):\n%v", d)
+	}
+}
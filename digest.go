@@ -0,0 +1,65 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// digestAlgorithm is a hash algorithm for file and payload digests.
+type digestAlgorithm struct {
+	// id is the PGP hash algorithm id rpm uses in the digest algo tags.
+	id  int32
+	new func() hash.Hash
+}
+
+var digestAlgorithms = map[string]digestAlgorithm{
+	"md5":    {hashAlgoMD5, md5.New},
+	"sha1":   {hashAlgoSHA1, sha1.New},
+	"sha256": {hashAlgoSHA256, sha256.New},
+	"sha384": {hashAlgoSHA384, sha512.New384},
+	"sha512": {hashAlgoSHA512, sha512.New},
+}
+
+// setupDigest returns the digest algorithm for the RPMMetaData.DigestAlgorithm
+// setting, and its normalized name. The default is sha256.
+func setupDigest(setting string) (digestAlgorithm, string, error) {
+	name := strings.ToLower(setting)
+	if name == "" {
+		name = "sha256"
+	}
+	algo, ok := digestAlgorithms[name]
+	if !ok {
+		return digestAlgorithm{}, "", fmt.Errorf("unknown digest algorithm: %s", setting)
+	}
+	return algo, name, nil
+}
+
+// digestNameFromID returns the name of the digest algorithm with the PGP
+// hash algorithm id.
+func digestNameFromID(id int32) (string, error) {
+	for name, algo := range digestAlgorithms {
+		if algo.id == id {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown digest algorithm id: %d", id)
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDigestAlgorithm(t *testing.T) {
+	testCases := []struct {
+		setting    string
+		wantName   string
+		wantID     int32
+		wantDigest string
+	}{{
+		setting:    "",
+		wantName:   "sha256",
+		wantID:     hashAlgoSHA256,
+		wantDigest: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	}, {
+		setting:    "md5",
+		wantName:   "md5",
+		wantID:     hashAlgoMD5,
+		wantDigest: "5d41402abc4b2a76b9719d911017c592",
+	}, {
+		setting:    "SHA1",
+		wantName:   "sha1",
+		wantID:     hashAlgoSHA1,
+		wantDigest: "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+	}, {
+		setting:    "sha512",
+		wantName:   "sha512",
+		wantID:     hashAlgoSHA512,
+		wantDigest: "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.wantName, func(t *testing.T) {
+			r, err := NewRPM(RPMMetaData{DigestAlgorithm: tc.setting})
+			if err != nil {
+				t.Fatalf("NewRPM returned error %v", err)
+			}
+			r.AddFile(RPMFile{Name: "/hello", Body: []byte("hello")})
+			if err := r.Write(ioutil.Discard); err != nil {
+				t.Fatalf("Write returned error %v", err)
+			}
+			if r.DigestAlgorithm != tc.wantName {
+				t.Errorf("DigestAlgorithm = %q, want %q", r.DigestAlgorithm, tc.wantName)
+			}
+			if r.filedigests[0] != tc.wantDigest {
+				t.Errorf("file digest = %s, want %s", r.filedigests[0], tc.wantDigest)
+			}
+			algos, err := r.headers.entries[tagFileDigestAlgo].toInt32Array()
+			if err != nil || algos[0] != tc.wantID {
+				t.Errorf("file digest algo = %v (%v), want %d", algos, err, tc.wantID)
+			}
+			if algo, err := r.headers.entries[tagPayloadDigestAlgo].toUint32(); err != nil || int32(algo) != tc.wantID {
+				t.Errorf("payload digest algo = %d (%v), want %d", algo, err, tc.wantID)
+			}
+		})
+	}
+}
+
+func TestDigestAlgorithmUnknown(t *testing.T) {
+	if _, err := NewRPM(RPMMetaData{DigestAlgorithm: "crc32"}); err == nil {
+		t.Error("NewRPM should fail with an unknown digest algorithm")
+	}
+}
+
+func TestDigestAlgorithmRead(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "md5", DigestAlgorithm: "md5"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/hello", Body: []byte("hello")})
+	f, err := os.CreateTemp(t.TempDir(), "md5-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	if got.DigestAlgorithm != "md5" {
+		t.Errorf("DigestAlgorithm = %q, want md5", got.DigestAlgorithm)
+	}
+}
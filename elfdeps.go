@@ -0,0 +1,222 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Flags of the ELF version sections.
+const (
+	elfVerFlagBase = 0x1
+)
+
+var errELFVersions = errors.New("invalid ELF version section")
+
+// ELFDependencies is a DependencyGenerator for ELF executables and shared
+// libraries, like rpm's elfdeps. The libraries of DT_NEEDED and the symbol
+// versions used from them become requires, e.g. `libc.so.6()(64bit)` and
+// `libc.so.6(GLIBC_2.34)(64bit)`, and the DT_SONAME of a library and the
+// versions it defines become provides. 32 bit files have no `(64bit)`
+// marker, e.g. `libc.so.6` and `libc.so.6(GLIBC_2.0)`. Like rpmbuild, only
+// files with an execute bit are looked at.
+type ELFDependencies struct{}
+
+// elfVersion is a symbol version of an ELF file, and the library it is
+// needed from.
+type elfVersion struct {
+	file, name string
+}
+
+// Generate implements DependencyGenerator.
+func (ELFDependencies) Generate(f RPMFile, content *io.SectionReader) (provides, requires Relations, err error) {
+	if f.Mode&0111 == 0 {
+		return nil, nil, nil
+	}
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := content.ReadAt(magic, 0); err != nil || string(magic) != elf.ELFMAG {
+		return nil, nil, nil
+	}
+	e, err := elf.NewFile(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read ELF file: %w", err)
+	}
+
+	marker := ""
+	// Alpha has no 64 bit marker, as it never had 32 bit libraries.
+	if e.Class == elf.ELFCLASS64 && e.Machine != elf.EM_ALPHA {
+		marker = "(64bit)"
+	}
+	dep := func(soname, version string) *Relation {
+		if version == "" && marker == "" {
+			return &Relation{Name: soname}
+		}
+		return &Relation{Name: soname + "(" + version + ")" + marker}
+	}
+
+	needed, err := e.DynString(elf.DT_NEEDED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read DT_NEEDED: %w", err)
+	}
+	for _, lib := range needed {
+		requires.addIfMissing(dep(lib, ""))
+	}
+	needs, err := elfVersionNeeds(e)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range needs {
+		requires.addIfMissing(dep(v.file, v.name))
+	}
+	// Only the dynamic linker of glibc 2.5 and later understands GNU hash
+	// tables.
+	if e.SectionByType(elf.SHT_GNU_HASH) != nil && e.SectionByType(elf.SHT_HASH) == nil {
+		requires.addIfMissing(&Relation{Name: "rtld(GNU_HASH)"})
+	}
+
+	if e.Type != elf.ET_DYN {
+		return provides, requires, nil
+	}
+	sonames, err := e.DynString(elf.DT_SONAME)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read DT_SONAME: %w", err)
+	}
+	if len(sonames) == 0 {
+		return provides, requires, nil
+	}
+	provides.addIfMissing(dep(sonames[0], ""))
+	defs, err := elfVersionDefs(e)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range defs {
+		provides.addIfMissing(dep(sonames[0], v))
+	}
+	return provides, requires, nil
+}
+
+// elfVersionSection returns the content of a version section and of the
+// string table it refers to.
+func elfVersionSection(e *elf.File, s *elf.Section) (data, strs []byte, err error) {
+	if int(s.Link) >= len(e.Sections) {
+		return nil, nil, errELFVersions
+	}
+	if data, err = s.Data(); err != nil {
+		return nil, nil, err
+	}
+	if strs, err = e.Sections[s.Link].Data(); err != nil {
+		return nil, nil, err
+	}
+	return data, strs, nil
+}
+
+// elfString returns the NUL terminated string at off of a string table.
+func elfString(strs []byte, off uint32) (string, error) {
+	if int64(off) >= int64(len(strs)) {
+		return "", errELFVersions
+	}
+	s := strs[off:]
+	if i := bytes.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return string(s), nil
+}
+
+// elfVersionNeeds reads the versions of SHT_GNU_VERNEED, the symbol versions
+// needed from other libraries.
+func elfVersionNeeds(e *elf.File) ([]elfVersion, error) {
+	s := e.SectionByType(elf.SHT_GNU_VERNEED)
+	if s == nil {
+		return nil, nil
+	}
+	data, strs, err := elfVersionSection(e, s)
+	if err != nil {
+		return nil, err
+	}
+	bo := e.ByteOrder
+	var versions []elfVersion
+	// Elf_Verneed and Elf_Vernaux are 16 bytes each.
+	for i, off := uint32(0), 0; i < s.Info; i++ {
+		if off < 0 || off+16 > len(data) {
+			return nil, errELFVersions
+		}
+		count := bo.Uint16(data[off+2:])
+		file, err := elfString(strs, bo.Uint32(data[off+4:]))
+		if err != nil {
+			return nil, err
+		}
+		aux := off + int(bo.Uint32(data[off+8:]))
+		for j := uint16(0); j < count; j++ {
+			if aux < 0 || aux+16 > len(data) {
+				return nil, errELFVersions
+			}
+			name, err := elfString(strs, bo.Uint32(data[aux+8:]))
+			if err != nil {
+				return nil, err
+			}
+			versions = append(versions, elfVersion{file: file, name: name})
+			aux += int(bo.Uint32(data[aux+12:]))
+		}
+		next := bo.Uint32(data[off+12:])
+		if next == 0 {
+			break
+		}
+		off += int(next)
+	}
+	return versions, nil
+}
+
+// elfVersionDefs reads the versions of SHT_GNU_VERDEF, the symbol versions
+// the library defines, without the base version naming the library itself.
+func elfVersionDefs(e *elf.File) ([]string, error) {
+	s := e.SectionByType(elf.SHT_GNU_VERDEF)
+	if s == nil {
+		return nil, nil
+	}
+	data, strs, err := elfVersionSection(e, s)
+	if err != nil {
+		return nil, err
+	}
+	bo := e.ByteOrder
+	var versions []string
+	// Elf_Verdef is 20 bytes, and Elf_Verdaux 8 bytes.
+	for i, off := uint32(0), 0; i < s.Info; i++ {
+		if off < 0 || off+20 > len(data) {
+			return nil, errELFVersions
+		}
+		flags := bo.Uint16(data[off+2:])
+		aux := off + int(bo.Uint32(data[off+12:]))
+		if flags&elfVerFlagBase == 0 && bo.Uint16(data[off+6:]) > 0 {
+			if aux < 0 || aux+8 > len(data) {
+				return nil, errELFVersions
+			}
+			name, err := elfString(strs, bo.Uint32(data[aux:]))
+			if err != nil {
+				return nil, err
+			}
+			versions = append(versions, name)
+		}
+		next := bo.Uint32(data[off+16:])
+		if next == 0 {
+			break
+		}
+		off += int(next)
+	}
+	return versions, nil
+}
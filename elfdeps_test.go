@@ -0,0 +1,158 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"debug/elf"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestELFDependencies(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mode         uint
+		body         []byte
+		wantProvides Relations
+		wantRequires Relations
+	}{{
+		name: "executable",
+		mode: 0755,
+		body: testELF{
+			class:   elf.ELFCLASS64,
+			typ:     elf.ET_DYN,
+			needed:  []string{"libssl.so.3", "libc.so.6"},
+			verneed: []elfVersion{{"libssl.so.3", "OPENSSL_3.0.0"}, {"libc.so.6", "GLIBC_2.34"}, {"libc.so.6", "GLIBC_2.2.5"}},
+			gnuHash: true,
+		}.bytes(),
+		wantRequires: Relations{
+			{Name: "libssl.so.3()(64bit)"},
+			{Name: "libc.so.6()(64bit)"},
+			{Name: "libssl.so.3(OPENSSL_3.0.0)(64bit)"},
+			{Name: "libc.so.6(GLIBC_2.34)(64bit)"},
+			{Name: "libc.so.6(GLIBC_2.2.5)(64bit)"},
+			{Name: "rtld(GNU_HASH)"},
+		},
+	}, {
+		name: "library",
+		mode: 0755,
+		body: testELF{
+			class:   elf.ELFCLASS64,
+			typ:     elf.ET_DYN,
+			needed:  []string{"libc.so.6"},
+			soname:  "libfoo.so.1",
+			verdef:  []string{"FOO_1.0", "FOO_1.1"},
+			verneed: []elfVersion{{"libc.so.6", "GLIBC_2.14"}},
+		}.bytes(),
+		wantProvides: Relations{
+			{Name: "libfoo.so.1()(64bit)"},
+			{Name: "libfoo.so.1(FOO_1.0)(64bit)"},
+			{Name: "libfoo.so.1(FOO_1.1)(64bit)"},
+		},
+		wantRequires: Relations{
+			{Name: "libc.so.6()(64bit)"},
+			{Name: "libc.so.6(GLIBC_2.14)(64bit)"},
+		},
+	}, {
+		name: "32 bit library",
+		mode: 0755,
+		body: testELF{
+			class:   elf.ELFCLASS32,
+			typ:     elf.ET_DYN,
+			needed:  []string{"libc.so.6"},
+			soname:  "libfoo.so.1",
+			verdef:  []string{"FOO_1.0"},
+			verneed: []elfVersion{{"libc.so.6", "GLIBC_2.0"}},
+		}.bytes(),
+		wantProvides: Relations{
+			{Name: "libfoo.so.1"},
+			{Name: "libfoo.so.1(FOO_1.0)"},
+		},
+		wantRequires: Relations{
+			{Name: "libc.so.6"},
+			{Name: "libc.so.6(GLIBC_2.0)"},
+		},
+	}, {
+		name: "soname of executable",
+		mode: 0755,
+		body: testELF{class: elf.ELFCLASS64, typ: elf.ET_EXEC, soname: "libfoo.so.1"}.bytes(),
+	}, {
+		name: "not executable",
+		mode: 0644,
+		body: testELF{class: elf.ELFCLASS64, typ: elf.ET_DYN, needed: []string{"libc.so.6"}}.bytes(),
+	}, {
+		name: "not ELF",
+		mode: 0755,
+		body: []byte("#!/bin/sh\n"),
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			provides, requires, err := ELFDependencies{}.Generate(RPMFile{Mode: tc.mode}, io.NewSectionReader(bytes.NewReader(tc.body), 0, int64(len(tc.body))))
+			if err != nil {
+				t.Fatalf("Generate returned err: %v", err)
+			}
+			if d := cmp.Diff(tc.wantProvides, provides); d != "" {
+				t.Errorf("provides differ (want->got):\n%v", d)
+			}
+			if d := cmp.Diff(tc.wantRequires, requires); d != "" {
+				t.Errorf("requires differ (want->got):\n%v", d)
+			}
+		})
+	}
+}
+
+func TestELFDependenciesCorrupt(t *testing.T) {
+	body := testELF{class: elf.ELFCLASS64, typ: elf.ET_DYN, needed: []string{"libc.so.6"}}.bytes()
+	body = body[:100]
+	if _, _, err := (ELFDependencies{}).Generate(RPMFile{Mode: 0755}, io.NewSectionReader(bytes.NewReader(body), 0, int64(len(body)))); err == nil {
+		t.Error("Generate returned no error for a truncated ELF file")
+	}
+}
+
+func TestELFDependenciesRoundTrip(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "foo", Version: "1"})
+	if err != nil {
+		t.Fatalf("NewRPM returned err: %v", err)
+	}
+	r.AddDependencyGenerator(ELFDependencies{})
+	r.AddFile(RPMFile{
+		Name: "/usr/lib64/libfoo.so.1",
+		Mode: 0100755,
+		Body: testELF{class: elf.ELFCLASS64, typ: elf.ET_DYN, needed: []string{"libc.so.6"}, soname: "libfoo.so.1"}.bytes(),
+	})
+	f, err := os.CreateTemp(t.TempDir(), "elf.rpm")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer f.Close()
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned err: %v", err)
+	}
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned err: %v", err)
+	}
+	if !containsRelation(got.Provides, "libfoo.so.1()(64bit)") {
+		t.Errorf("provides %v are missing libfoo.so.1()(64bit)", got.Provides)
+	}
+	if !containsRelation(got.Requires, "libc.so.6()(64bit)") {
+		t.Errorf("requires %v are missing libc.so.6()(64bit)", got.Requires)
+	}
+}
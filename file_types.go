@@ -1,5 +1,11 @@
 package rpmpack
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
 // FileType is the type of a file inside a RPM package.
 type FileType int32
 
@@ -37,6 +43,19 @@ const (
 	Artifact
 )
 
+// File type bits of RPMFile.Mode, as in stat(2). A mode without type bits is
+// a regular file.
+const (
+	modeTypeMask = 0170000
+	modeSocket   = 0140000
+	modeSymlink  = 0120000
+	modeRegular  = 0100000
+	modeBlock    = 060000
+	modeDir      = 040000
+	modeChar     = 020000
+	modeFIFO     = 010000
+)
+
 // RPMFile contains a particular file's entry and data.
 type RPMFile struct {
 	Name  string
@@ -46,4 +65,69 @@ type RPMFile struct {
 	Group string
 	MTime uint32
 	Type  FileType
+	// Source, when set, is used instead of Body to read the content of a
+	// regular file. It is called once while the rpm is written, or twice
+	// when dependency generators are set and the reader implements
+	// io.ReaderAt. The returned reader must yield exactly Size bytes. This
+	// allows packaging files which do not fit in memory.
+	Source func() (io.ReadCloser, error)
+	// Size is the size of the content returned by Source.
+	Size int64
+	// HardLink, when set, makes the file a hard link to the named regular
+	// file of the package. Content and attributes other than Name, Type and
+	// NoVerify are taken from that file.
+	HardLink string
+	// DevMajor and DevMinor are the device numbers of character and block
+	// devices (Mode 020000 and 060000). rpm stores them in 16 bits, so
+	// both are limited to 255, and Write fails for larger numbers.
+	DevMajor uint32
+	DevMinor uint32
+	// NoVerify are the checks `rpm -V` skips for this file, like
+	// `%verify(not md5 size mtime)`. By default everything is checked.
+	NoVerify VerifyFlags
+	// Capabilities are the POSIX file capabilities set on the file when it
+	// is installed, in the text form of cap_from_text(3), e.g.
+	// `cap_net_raw=ep`.
+	Capabilities string
+	// Lang is the language of the file, e.g. `de`, so rpm can skip it when
+	// the language is not installed. Files under /usr/share/locale get the
+	// language of their directory by default.
+	Lang string
+}
+
+// rdev returns the device number of the file, as stored by rpm.
+func (f RPMFile) rdev() (int16, error) {
+	switch f.Mode & modeTypeMask {
+	case modeChar, modeBlock:
+		if f.DevMajor > 0xff || f.DevMinor > 0xff {
+			return 0, fmt.Errorf("device %d,%d does not fit in rpm's 8 bit major and minor numbers", f.DevMajor, f.DevMinor)
+		}
+		return int16(f.DevMajor<<8 | f.DevMinor), nil
+	}
+	return 0, nil
+}
+
+// setRDev sets the device numbers from the value stored by rpm.
+func (f *RPMFile) setRDev(rdev int16) {
+	switch f.Mode & modeTypeMask {
+	case modeChar, modeBlock:
+		f.DevMajor = uint32(uint16(rdev) >> 8)
+		f.DevMinor = uint32(uint16(rdev) & 0xff)
+	}
+}
+
+// size returns the size of the content of the file.
+func (f RPMFile) size() int64 {
+	if f.Source != nil {
+		return f.Size
+	}
+	return int64(len(f.Body))
+}
+
+// open returns a reader for the content of the file.
+func (f RPMFile) open() (io.ReadCloser, error) {
+	if f.Source != nil {
+		return f.Source()
+	}
+	return io.NopCloser(bytes.NewReader(f.Body)), nil
 }
@@ -0,0 +1,180 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultFileTriggerPriority is the priority rpm gives to file triggers which
+// don't set one. Triggers with a higher priority run first.
+const DefaultFileTriggerPriority = 1000000
+
+var (
+	// ErrFileTriggerType is returned when a file trigger has no valid type.
+	ErrFileTriggerType = errors.New("file trigger type must be one of TriggerIn, TriggerUn or TriggerPostun")
+	// ErrFileTriggerNoPrefixes is returned when a file trigger has no prefixes.
+	ErrFileTriggerNoPrefixes = errors.New("file trigger has no prefixes")
+)
+
+// FileTrigger is a scriptlet run when files under one of its prefixes are
+// installed or removed by any package. The paths of the matching files are
+// passed to the script on stdin.
+// https://rpm-software-management.github.io/rpm/manual/file_triggers.html
+type FileTrigger struct {
+	// Type is one of TriggerIn, TriggerUn or TriggerPostun.
+	Type rpmSense
+	// Prefixes are the absolute paths watched by the trigger, e.g.
+	// `/usr/lib/ourapp/plugins`.
+	Prefixes []string
+	// Priority orders the file triggers, higher runs first. Zero means
+	// DefaultFileTriggerPriority.
+	Priority uint32
+	// Script is run when the trigger fires. At most one interpreter element
+	// is allowed.
+	Script Scriptlet
+}
+
+func (t FileTrigger) validate() error {
+	switch t.Type {
+	case TriggerIn, TriggerUn, TriggerPostun:
+	default:
+		return ErrFileTriggerType
+	}
+	if len(t.Prefixes) == 0 {
+		return ErrFileTriggerNoPrefixes
+	}
+	for _, p := range t.Prefixes {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("file trigger prefix %q is not an absolute path", p)
+		}
+	}
+	if len(t.Script.Interpreter) > 1 {
+		return fmt.Errorf("file trigger interpreter %q can't take arguments", t.Script.Interpreter)
+	}
+	return nil
+}
+
+// fileTriggerTags are the tags of a family of file triggers.
+type fileTriggerTags struct {
+	triggerTags
+	priorities int
+}
+
+var (
+	fileTriggerTagSet = fileTriggerTags{
+		triggerTags: triggerTags{
+			scripts:  tagFileTriggerScripts,
+			progs:    tagFileTriggerScriptProg,
+			names:    tagFileTriggerName,
+			versions: tagFileTriggerVersion,
+			flags:    tagFileTriggerFlags,
+			indexes:  tagFileTriggerIndex,
+		},
+		priorities: tagFileTriggerPriorities,
+	}
+	transFileTriggerTagSet = fileTriggerTags{
+		triggerTags: triggerTags{
+			scripts:  tagTransFileTriggerScripts,
+			progs:    tagTransFileTriggerScriptProg,
+			names:    tagTransFileTriggerName,
+			versions: tagTransFileTriggerVersion,
+			flags:    tagTransFileTriggerFlags,
+			indexes:  tagTransFileTriggerIndex,
+		},
+		priorities: tagTransFileTriggerPriorities,
+	}
+)
+
+// fileTriggers holds the file triggers of a package, in the order they were
+// added.
+type fileTriggers []FileTrigger
+
+// AddToIndex adds the file triggers to the index. They share the layout of
+// regular triggers, with the prefixes as condition names, plus a priority per
+// script.
+func (t fileTriggers) AddToIndex(h *index, tags fileTriggerTags) {
+	if len(t) == 0 {
+		return
+	}
+	tr := make(triggers, len(t))
+	priorities := make([]uint32, len(t))
+	for i, ft := range t {
+		tr[i] = Trigger{Type: ft.Type, Script: ft.Script}
+		for _, p := range ft.Prefixes {
+			tr[i].Conditions = append(tr[i].Conditions, &Relation{Name: p})
+		}
+		priorities[i] = ft.Priority
+		if priorities[i] == 0 {
+			priorities[i] = DefaultFileTriggerPriority
+		}
+	}
+	tr.AddToIndex(h, tags.triggerTags)
+	h.Add(tags.priorities, EntryUint32(priorities))
+}
+
+// scriptlets returns the scripts of all the file triggers.
+func (t fileTriggers) scriptlets() []Scriptlet {
+	s := make([]Scriptlet, len(t))
+	for i, ft := range t {
+		s[i] = ft.Script
+	}
+	return s
+}
+
+// AddFileTrigger adds a file trigger, like %filetriggerin, run once for each
+// package installing or removing matching files.
+func (r *RPM) AddFileTrigger(t FileTrigger) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	r.fileTriggers = append(r.fileTriggers, t)
+	return nil
+}
+
+// AddTransFileTrigger adds a transaction file trigger, like
+// %transfiletriggerin, run once per transaction for all the matching files.
+func (r *RPM) AddTransFileTrigger(t FileTrigger) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	r.transFileTriggers = append(r.transFileTriggers, t)
+	return nil
+}
+
+// readFileTriggers rebuilds a family of file triggers from the header.
+func readFileTriggers(h *index, tags fileTriggerTags) (fileTriggers, error) {
+	tr, err := readTriggers(h, tags.triggerTags)
+	if err != nil {
+		return nil, err
+	}
+	priorities, _ := popTag(h.entries, tags.priorities, IndexEntry.toUint32Array)
+	if len(tr) == 0 {
+		return nil, nil
+	}
+	if len(priorities) != len(tr) {
+		return nil, fmt.Errorf("mismatch in file trigger priorities count %d, want %d", len(priorities), len(tr))
+	}
+	out := make(fileTriggers, len(tr))
+	for i, t := range tr {
+		out[i] = FileTrigger{Type: t.Type, Priority: priorities[i], Script: t.Script}
+		for _, c := range t.Conditions {
+			out[i].Prefixes = append(out[i].Prefixes, c.Name)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAddFileTriggerValidation(t *testing.T) {
+	plugins := []string{"/usr/lib/ourapp/plugins"}
+	testCases := []struct {
+		name    string
+		trigger FileTrigger
+		wantErr bool
+		wantIs  error
+	}{{
+		name:    "valid",
+		trigger: FileTrigger{Type: TriggerIn, Prefixes: plugins},
+	}, {
+		name:    "prein is not a file trigger",
+		trigger: FileTrigger{Type: TriggerPrein, Prefixes: plugins},
+		wantErr: true,
+		wantIs:  ErrFileTriggerType,
+	}, {
+		name:    "no prefixes",
+		trigger: FileTrigger{Type: TriggerUn},
+		wantErr: true,
+		wantIs:  ErrFileTriggerNoPrefixes,
+	}, {
+		name:    "relative prefix",
+		trigger: FileTrigger{Type: TriggerIn, Prefixes: []string{"usr/lib"}},
+		wantErr: true,
+	}, {
+		name: "interpreter arguments",
+		trigger: FileTrigger{
+			Type:     TriggerIn,
+			Prefixes: plugins,
+			Script:   NewScriptlet("cat", "/bin/bash", "-e"),
+		},
+		wantErr: true,
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRPM(RPMMetaData{})
+			if err != nil {
+				t.Fatalf("NewRPM returned error %v", err)
+			}
+			for name, add := range map[string]func(FileTrigger) error{
+				"AddFileTrigger":      r.AddFileTrigger,
+				"AddTransFileTrigger": r.AddTransFileTrigger,
+			} {
+				err := add(tc.trigger)
+				if (err != nil) != tc.wantErr {
+					t.Fatalf("%s returned error %v, want error %v", name, err, tc.wantErr)
+				}
+				if tc.wantIs != nil && !errors.Is(err, tc.wantIs) {
+					t.Errorf("%s returned error %v, want %v", name, err, tc.wantIs)
+				}
+			}
+		})
+	}
+}
+
+func TestFileTriggersIndex(t *testing.T) {
+	ft := fileTriggers{{
+		Type:     TriggerIn,
+		Prefixes: []string{"/usr/lib/ourapp/plugins", "/usr/share/ourapp/plugins"},
+		Script:   NewScriptlet("ourapp --rebuild-cache"),
+	}, {
+		Type:     TriggerPostun,
+		Prefixes: []string{"/usr/lib/ourapp/plugins"},
+		Priority: 10,
+		Script:   NewScriptlet("print('removed')", LuaInterpreter),
+	}}
+	h := newIndex(immutable)
+	ft.AddToIndex(h, transFileTriggerTagSet)
+
+	want := map[int]IndexEntry{
+		tagTransFileTriggerName:       EntryStringSlice([]string{"/usr/lib/ourapp/plugins", "/usr/share/ourapp/plugins", "/usr/lib/ourapp/plugins"}),
+		tagTransFileTriggerVersion:    EntryStringSlice([]string{"", "", ""}),
+		tagTransFileTriggerFlags:      EntryUint32([]uint32{uint32(TriggerIn), uint32(TriggerIn), uint32(TriggerPostun)}),
+		tagTransFileTriggerIndex:      EntryInt32([]int32{0, 0, 1}),
+		tagTransFileTriggerScripts:    EntryStringSlice([]string{"ourapp --rebuild-cache", "print('removed')"}),
+		tagTransFileTriggerScriptProg: EntryStringSlice([]string{"/bin/sh", "<lua>"}),
+		tagTransFileTriggerPriorities: EntryUint32([]uint32{DefaultFileTriggerPriority, 10}),
+	}
+	if d := cmp.Diff(want, h.entries, cmp.AllowUnexported(IndexEntry{})); d != "" {
+		t.Errorf("index differs (want->got):\n%v", d)
+	}
+}
+
+func TestFileTriggersRoundTrip(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "ourapp"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	wantFile := fileTriggers{{
+		Type:     TriggerIn,
+		Prefixes: []string{"/usr/lib/ourapp/plugins"},
+		Priority: DefaultFileTriggerPriority,
+		Script:   Scriptlet{Body: "ourapp --add-plugins", Interpreter: []string{"/bin/sh"}},
+	}}
+	wantTrans := fileTriggers{{
+		Type:     TriggerIn,
+		Prefixes: []string{"/usr/lib/ourapp/plugins", "/etc/ourapp/plugins.d"},
+		Priority: 100,
+		Script:   Scriptlet{Body: "ourapp --rebuild-cache", Interpreter: []string{"/bin/bash"}},
+	}, {
+		Type:     TriggerPostun,
+		Prefixes: []string{"/usr/lib/ourapp/plugins"},
+		Priority: 200,
+		Script:   Scriptlet{Body: "ourapp --rebuild-cache", Interpreter: []string{"/bin/sh"}},
+	}}
+	for _, ft := range wantFile {
+		if err := r.AddFileTrigger(ft); err != nil {
+			t.Fatalf("AddFileTrigger returned error %v", err)
+		}
+	}
+	for _, ft := range wantTrans {
+		if err := r.AddTransFileTrigger(ft); err != nil {
+			t.Fatalf("AddTransFileTrigger returned error %v", err)
+		}
+	}
+
+	f, err := os.CreateTemp(t.TempDir(), "filetriggers-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	fileTriggersReq := &Relation{Name: "rpmlib(FileTriggers)", Version: "4.13.0-1", Sense: SenseRPMLIB | SenseLess | SenseEqual}
+	found := false
+	for _, req := range r.Requires {
+		found = found || req.Equal(fileTriggersReq)
+	}
+	if !found {
+		t.Errorf("Requires %v is missing rpmlib(FileTriggers)", r.Requires.String())
+	}
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	if d := cmp.Diff(wantFile, got.fileTriggers); d != "" {
+		t.Errorf("file triggers differ (want->got):\n%v", d)
+	}
+	if d := cmp.Diff(wantTrans, got.transFileTriggers); d != "" {
+		t.Errorf("transaction file triggers differ (want->got):\n%v", d)
+	}
+	if len(got.triggers) != 0 {
+		t.Errorf("got unexpected package triggers %v", got.triggers)
+	}
+}
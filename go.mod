@@ -11,6 +11,7 @@ require (
 	github.com/klauspost/compress v1.17.4
 	github.com/klauspost/pgzip v1.2.6
 	github.com/ulikunitz/xz v0.5.11
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,122 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"debug/buildinfo"
+	"debug/elf"
+	"fmt"
+	"io"
+	"regexp"
+	"runtime/debug"
+	"strings"
+)
+
+var goToolchainRE = regexp.MustCompile(`^go(\d+(?:\.\d+)*)((?:rc|beta)\d+)?$`)
+
+// GoBuildInfoDependencies is a DependencyGenerator for Go executables. The
+// build information Go embeds in ELF binaries provides
+// `bundled(golang(module)) = version` for the main module and every module
+// it was built with, so that scanners can match the package against
+// vulnerabilities. Versions are converted to sort the same in rpm, e.g.
+// `v1.2.0-rc.1` is `1.2.0~rc.1`. Like rpmbuild, only files with an execute
+// bit are looked at.
+type GoBuildInfoDependencies struct {
+	// Toolchain also provides `bundled(golang) = version` for the Go
+	// toolchain, e.g. `bundled(golang) = 1.21.1`, which the standard library
+	// comes from.
+	Toolchain bool
+	// SkipReplaced leaves out modules which were replaced. By default they
+	// are provided with the version of their replacement, or without a
+	// version if it is a local directory.
+	SkipReplaced bool
+}
+
+// Generate implements DependencyGenerator.
+func (g GoBuildInfoDependencies) Generate(f RPMFile, content *io.SectionReader) (provides, requires Relations, err error) {
+	if f.Mode&0111 == 0 {
+		return nil, nil, nil
+	}
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := content.ReadAt(magic, 0); err != nil || string(magic) != elf.ELFMAG {
+		return nil, nil, nil
+	}
+	e, err := elf.NewFile(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read ELF file: %w", err)
+	}
+	if e.Section(".go.buildinfo") == nil {
+		return nil, nil, nil
+	}
+	bi, err := buildinfo.Read(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read Go build info: %w", err)
+	}
+	return g.provides(bi), nil, nil
+}
+
+// provides returns the provides of the build information of a binary.
+func (g GoBuildInfoDependencies) provides(bi *debug.BuildInfo) Relations {
+	var provides Relations
+	add := func(name, version string) {
+		rel := &Relation{Name: name}
+		if version != "" {
+			rel.Version, rel.Sense = version, SenseEqual
+		}
+		provides.addIfMissing(rel)
+	}
+	if g.Toolchain {
+		if m := goToolchainRE.FindStringSubmatch(strings.Fields(bi.GoVersion + " ")[0]); m != nil {
+			v := m[1]
+			if m[2] != "" {
+				v += "~" + m[2]
+			}
+			add("bundled(golang)", v)
+		}
+	}
+	// Binaries built from files rather than a package have no main module.
+	if bi.Main.Path != "" && bi.Main.Path != "command-line-arguments" {
+		add("bundled(golang("+bi.Main.Path+"))", goModuleVersion(bi.Main.Version))
+	}
+	for _, m := range bi.Deps {
+		version := m.Version
+		if m.Replace != nil {
+			if g.SkipReplaced {
+				continue
+			}
+			version = m.Replace.Version
+		}
+		add("bundled(golang("+m.Path+"))", goModuleVersion(version))
+	}
+	return provides
+}
+
+// goModuleVersion converts a module version to a version which sorts the same
+// in rpm: the pre-release of semantic versions, which pseudo-versions also
+// use, sorts before the release with `~`, e.g. `v0.0.0-20230101120000-abcdef`
+// is `0.0.0~20230101120000.abcdef`. Build metadata, like `+incompatible`, is
+// dropped, and the `(devel)` version of a main module built from a checkout
+// is empty.
+func goModuleVersion(v string) string {
+	if v == "(devel)" {
+		return ""
+	}
+	v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "+")
+	release, pre, ok := strings.Cut(v, "-")
+	if !ok {
+		return release
+	}
+	return release + "~" + strings.ReplaceAll(pre, "-", ".")
+}
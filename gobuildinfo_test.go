@@ -0,0 +1,141 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"debug/elf"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGoBuildInfoDependencies(t *testing.T) {
+	// The test binary is a Go executable, with the build info of this module.
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable returned err: %v", err)
+	}
+	b, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", exe, err)
+	}
+	if !bytes.HasPrefix(b, []byte(elf.ELFMAG)) {
+		t.Skip("the test binary is not an ELF file")
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		t.Skip("the test binary has no build info")
+	}
+	g := GoBuildInfoDependencies{Toolchain: true}
+	provides, requires, err := g.Generate(RPMFile{Name: "/usr/bin/foo", Mode: 0755}, io.NewSectionReader(bytes.NewReader(b), 0, int64(len(b))))
+	if err != nil {
+		t.Fatalf("Generate returned err: %v", err)
+	}
+	if requires != nil {
+		t.Errorf("Generate returned requires %v, want none", requires)
+	}
+	if d := cmp.Diff(g.provides(bi), provides); d != "" {
+		t.Errorf("provides differ (want->got):\n%v", d)
+	}
+	for _, dep := range bi.Deps {
+		if !containsRelation(provides, "bundled(golang("+dep.Path+"))") {
+			t.Errorf("provides %v are missing %s", provides, dep.Path)
+		}
+	}
+
+	if provides, _, err := g.Generate(RPMFile{Mode: 0644}, io.NewSectionReader(bytes.NewReader(b), 0, int64(len(b)))); provides != nil || err != nil {
+		t.Errorf("Generate of a non executable file returned %v, %v, want nothing", provides, err)
+	}
+}
+
+func TestGoBuildInfoDependenciesOtherFiles(t *testing.T) {
+	for _, body := range []string{"#!/bin/sh\n", "", string(testELF{class: elf.ELFCLASS64, typ: elf.ET_EXEC, needed: []string{"libc.so.6"}}.bytes())} {
+		provides, requires, err := GoBuildInfoDependencies{}.Generate(RPMFile{Mode: 0755}, io.NewSectionReader(strings.NewReader(body), 0, int64(len(body))))
+		if err != nil || provides != nil || requires != nil {
+			t.Errorf("Generate(%q) returned %v, %v, %v, want nothing", body, provides, requires, err)
+		}
+	}
+}
+
+func TestGoBuildInfoProvides(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.22rc1 X:boringcrypto",
+		Main:      debug.Module{Path: "example.com/foo", Version: "(devel)"},
+		Deps: []*debug.Module{
+			{Path: "golang.org/x/sys", Version: "v0.15.0"},
+			{Path: "example.com/bar", Version: "v1.2.0", Replace: &debug.Module{Path: "example.com/bar", Version: "v1.2.1-rc.1"}},
+			{Path: "example.com/baz", Version: "v1.0.0", Replace: &debug.Module{Path: "../baz"}},
+		},
+	}
+	testCases := []struct {
+		name string
+		g    GoBuildInfoDependencies
+		want Relations
+	}{
+		{
+			name: "default",
+			want: Relations{
+				{Name: "bundled(golang(example.com/foo))"},
+				{Name: "bundled(golang(golang.org/x/sys))", Version: "0.15.0", Sense: SenseEqual},
+				{Name: "bundled(golang(example.com/bar))", Version: "1.2.1~rc.1", Sense: SenseEqual},
+				{Name: "bundled(golang(example.com/baz))"},
+			},
+		},
+		{
+			name: "toolchain without replaced",
+			g:    GoBuildInfoDependencies{Toolchain: true, SkipReplaced: true},
+			want: Relations{
+				{Name: "bundled(golang)", Version: "1.22~rc1", Sense: SenseEqual},
+				{Name: "bundled(golang(example.com/foo))"},
+				{Name: "bundled(golang(golang.org/x/sys))", Version: "0.15.0", Sense: SenseEqual},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if d := cmp.Diff(tc.want, tc.g.provides(bi)); d != "" {
+				t.Errorf("provides differ (want->got):\n%v", d)
+			}
+		})
+	}
+}
+
+func TestGoModuleVersion(t *testing.T) {
+	testCases := []struct {
+		version, want string
+	}{
+		{"v1.2.3", "1.2.3"},
+		{"v2.0.0+incompatible", "2.0.0"},
+		{"v1.0.0-rc.1", "1.0.0~rc.1"},
+		{"v0.0.0-20230101120000-abcdef012345", "0.0.0~20230101120000.abcdef012345"},
+		{"v1.2.4-0.20230101120000-abcdef012345", "1.2.4~0.20230101120000.abcdef012345"},
+		{"(devel)", ""},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.version, func(t *testing.T) {
+			if got := goModuleVersion(tc.version); got != tc.want {
+				t.Errorf("goModuleVersion(%q) want %q, got %q", tc.version, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"fmt"
+	"sort"
+)
+
+// linkGroup is a set of hard linked files, sharing one inode.
+// Like rpmbuild, the content is only written to the payload with the last
+// member of the group, the other members are empty cpio entries.
+type linkGroup struct {
+	target RPMFile
+	// names is sorted in payload order.
+	names []string
+	inode int32
+	// pending are the file indexes of the members written before the
+	// content, which still need their digest.
+	pending []int
+}
+
+// apply returns the member f with the content and attributes of the target.
+func (g *linkGroup) apply(f RPMFile) RPMFile {
+	out := g.target
+	out.Name = f.Name
+	out.Type = f.Type
+	out.NoVerify = f.NoVerify
+	out.Lang = f.Lang
+	return out
+}
+
+// last reports whether name is the member carrying the content.
+func (g *linkGroup) last(name string) bool {
+	return g.names[len(g.names)-1] == name
+}
+
+// resolveHardLinks groups all hard linked files by their target, and returns
+// the group of every member.
+func (r *RPM) resolveHardLinks() (map[string]*linkGroup, error) {
+	groups := map[string]*linkGroup{}
+	for fn, f := range r.files {
+		if f.HardLink == "" {
+			continue
+		}
+		target, err := r.hardLinkTarget(f)
+		if err != nil {
+			return nil, err
+		}
+		g, ok := groups[target.Name]
+		if !ok {
+			g = &linkGroup{target: target, names: []string{target.Name}}
+			groups[target.Name] = g
+		}
+		g.names = append(g.names, fn)
+		groups[fn] = g
+	}
+	for _, g := range groups {
+		sort.Strings(g.names)
+	}
+	return groups, nil
+}
+
+// hardLinkTarget follows the hard link of f, possibly through other hard
+// links, to a regular file.
+func (r *RPM) hardLinkTarget(f RPMFile) (RPMFile, error) {
+	if f.Type&GhostFile != 0 {
+		return RPMFile{}, fmt.Errorf("ghost file %q can not be a hard link", f.Name)
+	}
+	seen := map[string]bool{f.Name: true}
+	for f.HardLink != "" {
+		target, ok := r.files[f.HardLink]
+		if !ok {
+			return RPMFile{}, fmt.Errorf("hard link %q points to %q, which is not in the package", f.Name, f.HardLink)
+		}
+		if seen[target.Name] {
+			return RPMFile{}, fmt.Errorf("hard link loop at %q", target.Name)
+		}
+		seen[target.Name] = true
+		f = target
+	}
+	if t := f.Mode & modeTypeMask; (t != 0 && t != modeRegular) || f.Type&GhostFile != 0 {
+		return RPMFile{}, fmt.Errorf("hard link target %q is not a regular file", f.Name)
+	}
+	return f, nil
+}
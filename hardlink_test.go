@@ -0,0 +1,177 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cavaliergopher/cpio"
+	"github.com/google/go-cmp/cmp"
+	gzip "github.com/klauspost/pgzip"
+)
+
+func TestHardLinks(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "links"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/usr/bin/gcc", Body: []byte("compiler"), Mode: 0755})
+	r.AddFile(RPMFile{Name: "/usr/bin/cc", HardLink: "/usr/bin/gcc"})
+	r.AddFile(RPMFile{Name: "/usr/bin/x86_64-gcc", HardLink: "/usr/bin/cc"})
+	r.AddFile(RPMFile{Name: "/usr/bin/other", Body: []byte("other")})
+
+	f, err := os.CreateTemp(t.TempDir(), "links-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	// Sorted: cc, gcc, other, x86_64-gcc
+	if d := cmp.Diff([]int32{1, 1, 3, 1}, r.fileinodes); d != "" {
+		t.Errorf("inodes differ (want->got):\n%v", d)
+	}
+	if d := cmp.Diff([]uint64{8, 8, 5, 8}, r.filesizes); d != "" {
+		t.Errorf("file sizes differ (want->got):\n%v", d)
+	}
+	if r.filedigests[0] == "" || r.filedigests[0] != r.filedigests[1] || r.filedigests[0] != r.filedigests[3] {
+		t.Errorf("hard links should share one digest, got %v", r.filedigests)
+	}
+	if d := cmp.Diff([]uint16{0100755, 0100755, 0100000, 0100755}, r.filemodes); d != "" {
+		t.Errorf("file modes differ (want->got):\n%v", d)
+	}
+	if r.payloadSize != 13 {
+		t.Errorf("payload size want 13, got %d", r.payloadSize)
+	}
+
+	z, err := gzip.NewReader(r.payload.Reader())
+	if err != nil {
+		t.Fatalf("failed to open payload: %v", err)
+	}
+	c := cpio.NewReader(z)
+	gotSizes := map[string]int64{}
+	gotLinks := map[string]int{}
+	for {
+		h, err := c.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to read payload: %v", err)
+		}
+		gotSizes[h.Name] = h.Size
+		gotLinks[h.Name] = h.Links
+	}
+	wantSizes := map[string]int64{"./usr/bin/cc": 0, "./usr/bin/gcc": 0, "./usr/bin/other": 5, "./usr/bin/x86_64-gcc": 8}
+	if d := cmp.Diff(wantSizes, gotSizes); d != "" {
+		t.Errorf("payload sizes differ (want->got):\n%v", d)
+	}
+	wantLinks := map[string]int{"./usr/bin/cc": 3, "./usr/bin/gcc": 3, "./usr/bin/other": 1, "./usr/bin/x86_64-gcc": 3}
+	if d := cmp.Diff(wantLinks, gotLinks); d != "" {
+		t.Errorf("payload links differ (want->got):\n%v", d)
+	}
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	for _, name := range []string{"/usr/bin/cc", "/usr/bin/gcc"} {
+		if link := got.files[name].HardLink; link != "/usr/bin/x86_64-gcc" {
+			t.Errorf("%s should be read back as hard link to /usr/bin/x86_64-gcc, got %q", name, link)
+		}
+	}
+	if body := string(got.files["/usr/bin/x86_64-gcc"].Body); body != "compiler" {
+		t.Errorf("hard link target content want %q, got %q", "compiler", body)
+	}
+}
+
+func TestHardLinksStripped(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "links"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/usr/bin/gcc", Body: []byte("compiler"), Mode: 0755})
+	r.AddFile(RPMFile{Name: "/usr/bin/cc", HardLink: "/usr/bin/gcc"})
+	r.AddFile(RPMFile{Name: "/usr/bin/other", Body: []byte("other")})
+	r.AddFile(RPMFile{Name: "/usr/bin/x86_64-gcc", HardLink: "/usr/bin/cc"})
+	// Force the stripped payload of files of 4GiB and more.
+	r.largeFiles = true
+
+	f, err := os.CreateTemp(t.TempDir(), "links-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	for _, name := range []string{"/usr/bin/cc", "/usr/bin/gcc"} {
+		if link := got.files[name].HardLink; link != "/usr/bin/x86_64-gcc" {
+			t.Errorf("%s should be read back as hard link to /usr/bin/x86_64-gcc, got %q", name, link)
+		}
+	}
+	if body := string(got.files["/usr/bin/x86_64-gcc"].Body); body != "compiler" {
+		t.Errorf("hard link target content want %q, got %q", "compiler", body)
+	}
+	if body := string(got.files["/usr/bin/other"].Body); body != "other" {
+		t.Errorf("content of the file after the hard links want %q, got %q", "other", body)
+	}
+}
+
+func TestHardLinkErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []RPMFile
+	}{{
+		name:  "missing target",
+		files: []RPMFile{{Name: "/a", HardLink: "/b"}},
+	}, {
+		name: "directory target",
+		files: []RPMFile{
+			{Name: "/a", HardLink: "/b"},
+			{Name: "/b", Mode: 040755},
+		},
+	}, {
+		name: "loop",
+		files: []RPMFile{
+			{Name: "/a", HardLink: "/b"},
+			{Name: "/b", HardLink: "/a"},
+		},
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRPM(RPMMetaData{})
+			if err != nil {
+				t.Fatalf("NewRPM returned error %v", err)
+			}
+			for _, f := range tc.files {
+				r.AddFile(f)
+			}
+			if err := r.Write(ioutil.Discard); err == nil {
+				t.Error("Write should have returned an error")
+			}
+		})
+	}
+}
@@ -30,6 +30,7 @@ const (
 
 	typeInt16       = 0x03
 	typeInt32       = 0x04
+	typeInt64       = 0x05
 	typeString      = 0x06
 	typeBinary      = 0x07
 	typeStringArray = 0x08
@@ -41,6 +42,7 @@ const (
 var boundaries = map[int]int{
 	typeInt16: 2,
 	typeInt32: 4,
+	typeInt64: 8,
 }
 
 type IndexEntry struct {
@@ -63,7 +65,11 @@ func (e IndexEntry) toString() (string, error) {
 		return "", fmt.Errorf("rpmtype %d is not a string type", e.rpmtype)
 	}
 
-	return string(e.data[:len(e.data)-1]), nil
+	// i18n strings hold one value per locale, the first one is for "C".
+	if end := bytes.IndexByte(e.data, '\x00'); end > -1 {
+		return string(e.data[:end]), nil
+	}
+	return string(e.data), nil
 }
 
 var IndexEntryToString = IndexEntry.toString
@@ -94,6 +100,19 @@ func (e IndexEntry) toUint32() (uint32, error) {
 	return value, nil
 }
 
+func (e IndexEntry) toUint64() (uint64, error) {
+	if e.rpmtype != typeInt64 {
+		return 0, fmt.Errorf("rpmtype %d is not a uint64 type", e.rpmtype)
+	}
+
+	b := &bytes.Buffer{}
+	b.Write(e.data)
+	value := uint64(0)
+	binary.Read(b, binary.BigEndian, &value)
+
+	return value, nil
+}
+
 func (e *index) toRelations(nameTag int, versionTag int, flagsTag int) (Relations, error) {
 	names, err := popTag(e.entries, nameTag, IndexEntry.toStringArray)
 	if err != nil {
@@ -149,7 +168,7 @@ func (e IndexEntry) toTime() (time.Time, error) {
 }
 
 func (e IndexEntry) toStringArray() ([]string, error) {
-	if e.rpmtype != typeStringArray {
+	if e.rpmtype != typeStringArray && e.rpmtype != typei18nString {
 		return nil, fmt.Errorf("rpmtype %d is not a string array type", e.rpmtype)
 	}
 
@@ -194,6 +213,18 @@ func (e IndexEntry) toUint32Array() ([]uint32, error) {
 	return out, nil
 }
 
+func (e IndexEntry) toUint64Array() ([]uint64, error) {
+	if e.rpmtype != typeInt64 {
+		return nil, fmt.Errorf("rpmtype %d is not an int64 type", e.rpmtype)
+	}
+	out := make([]uint64, e.count)
+	b := &bytes.Buffer{}
+	b.Write(e.data)
+	binary.Read(b, binary.BigEndian, &out)
+
+	return out, nil
+}
+
 func (e IndexEntry) toUint16Array() ([]uint16, error) {
 	if e.rpmtype != typeInt16 {
 		return nil, fmt.Errorf("rpmtype %d is not an int type", e.rpmtype)
@@ -256,6 +287,12 @@ func EntryInt32(value []int32) IndexEntry {
 func EntryUint32(value []uint32) IndexEntry {
 	return intEntry(typeInt32, len(value), value)
 }
+func EntryInt64(value []int64) IndexEntry {
+	return intEntry(typeInt64, len(value), value)
+}
+func EntryUint64(value []uint64) IndexEntry {
+	return intEntry(typeInt64, len(value), value)
+}
 func EntryString(value string) IndexEntry {
 	return IndexEntry{typeString, 1, append([]byte(value), byte(00))}
 }
@@ -272,6 +309,14 @@ func EntryStringSlice(value []string) IndexEntry {
 	return IndexEntry{typeStringArray, len(value), bb}
 }
 
+// EntryI18NStrings returns an i18n string entry, with one value per locale of
+// the i18n table.
+func EntryI18NStrings(value []string) IndexEntry {
+	e := EntryStringSlice(value)
+	e.rpmtype = typei18nString
+	return e
+}
+
 type index struct {
 	entries map[int]IndexEntry
 	h       int
@@ -375,6 +420,8 @@ func indexEntrySize(rpmtype int) int {
 		return 2
 	case typeInt32:
 		return 4
+	case typeInt64:
+		return 8
 	case typeString:
 		return 1
 	case typeBinary:
@@ -396,10 +443,10 @@ func readIndexEntry(entry IndexEntry, data []byte, offset int) ([]byte, error) {
 	if len(data) < offset + (size * entry.count) {
 		return nil, fmt.Errorf("buffer is too small size: %d, offset: %d, size: %d, count: %d", len(data), offset, size, entry.count)
 	}
-	if entry.rpmtype == typeInt16 || entry.rpmtype == typeInt32 {
+	if entry.rpmtype == typeInt16 || entry.rpmtype == typeInt32 || entry.rpmtype == typeInt64 {
 		return data[offset:offset + ( size * entry.count )], nil
 	}
-	if entry.rpmtype == typeString || entry.rpmtype == typei18nString {
+	if entry.rpmtype == typeString {
 		data = 	data[offset:]
 		end := bytes.IndexByte(data, '\x00')
 		if  end > -1 {
@@ -407,7 +454,7 @@ func readIndexEntry(entry IndexEntry, data []byte, offset int) ([]byte, error) {
 		}
 		return data, nil
 	}
-	if entry.rpmtype == typeStringArray {
+	if entry.rpmtype == typeStringArray || entry.rpmtype == typei18nString {
 		data = data[offset:]
 		out := []byte{}
 		offset = 0
@@ -519,6 +566,9 @@ func NewLead(data RPMMetaData) *Lead {
 	out.major = 0x03
 	out.minor = 0x00
 	out.typeFile = 0 // assume binary
+	if data.IsSource {
+		out.typeFile = 1
+	}
 	out.archNum = 0  // i386? lead is ignored so it doesn't matter
 	out.name = data.Name
 	out.osnum = 0x01 // linux?
@@ -45,6 +45,9 @@ func TestLead(t *testing.T) {
 	}
 }
 
+// i18nValue selects EntryI18NStrings in TestEntry.
+type i18nValue []string
+
 func TestEntry(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -60,6 +63,13 @@ func TestEntry(t *testing.T) {
 		offset:         5,
 		wantIndexBytes: "0000010d000000040000000500000001",
 		wantData:       "00000042",
+	}, {
+		name:           "int64",
+		value:          []int64{0x100000000},
+		tag:            0x1390,
+		offset:         8,
+		wantIndexBytes: "00001390000000050000000800000001",
+		wantData:       "0000000100000000",
 	}, {
 		name:           "simple string",
 		value:          "simple string",
@@ -74,6 +84,13 @@ func TestEntry(t *testing.T) {
 		offset:         0x222,
 		wantIndexBytes: "0000010f000000080000022200000002",
 		wantData:       "737472696e6700617272617900",
+	}, {
+		name:           "i18n string",
+		value:          i18nValue{"hi", "hallo"},
+		tag:            0x03ec,
+		offset:         0x333,
+		wantIndexBytes: "000003ec000000090000033300000002",
+		wantData:       "68690068616c6c6f00",
 	}}
 	for _, tc := range testCases {
 		tc := tc
@@ -82,10 +99,14 @@ func TestEntry(t *testing.T) {
 			switch v := tc.value.(type) {
 			case []string:
 				e = EntryStringSlice(v)
+			case i18nValue:
+				e = EntryI18NStrings(v)
 			case string:
 				e = EntryString(v)
 			case []int32:
 				e = EntryInt32(v)
+			case []int64:
+				e = EntryInt64(v)
 			}
 			gotBytes := e.indexBytes(tc.tag, tc.offset)
 			if d := cmp.Diff(tc.wantIndexBytes, fmt.Sprintf("%x", gotBytes)); d != "" {
@@ -0,0 +1,204 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+)
+
+// Helpers shared by tests of several files. Every go_test target in
+// BUILD.bazel using them lists this file in its srcs.
+
+// containsRelation reports whether rels has a relation with the name.
+func containsRelation(rels Relations, name string) bool {
+	for _, rel := range rels {
+		if rel.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// testELF describes a minimal little endian ELF file with the dynamic
+// section and symbol versions.
+type testELF struct {
+	class   elf.Class
+	typ     elf.Type
+	needed  []string
+	soname  string
+	verneed []elfVersion
+	verdef  []string
+	gnuHash bool
+}
+
+// bytes returns the ELF file, made of the ELF header, the section contents and
+// the section headers.
+func (te testELF) bytes() []byte {
+	le := binary.LittleEndian
+	var strs bytes.Buffer
+	strs.WriteByte(0)
+	str := func(s string) uint32 {
+		off := uint32(strs.Len())
+		strs.WriteString(s + "\x00")
+		return off
+	}
+
+	type section struct {
+		name       string
+		typ        elf.SectionType
+		data       []byte
+		link, info uint32
+	}
+	var sections []section
+
+	// .dynstr is section 1, referred to by the others.
+	var dyn bytes.Buffer
+	addDyn := func(tag elf.DynTag, val uint32) {
+		if te.class == elf.ELFCLASS64 {
+			binary.Write(&dyn, le, elf.Dyn64{Tag: int64(tag), Val: uint64(val)})
+		} else {
+			binary.Write(&dyn, le, elf.Dyn32{Tag: int32(tag), Val: val})
+		}
+	}
+	for _, lib := range te.needed {
+		addDyn(elf.DT_NEEDED, str(lib))
+	}
+	if te.soname != "" {
+		addDyn(elf.DT_SONAME, str(te.soname))
+	}
+	addDyn(elf.DT_NULL, 0)
+	sections = append(sections, section{name: ".dynamic", typ: elf.SHT_DYNAMIC, data: dyn.Bytes(), link: 1})
+
+	if len(te.verneed) > 0 {
+		var files []string
+		byFile := map[string][]string{}
+		for _, v := range te.verneed {
+			if byFile[v.file] == nil {
+				files = append(files, v.file)
+			}
+			byFile[v.file] = append(byFile[v.file], v.name)
+		}
+		var b bytes.Buffer
+		for i, file := range files {
+			names := byFile[file]
+			next := uint32(16 + 16*len(names))
+			if i == len(files)-1 {
+				next = 0
+			}
+			binary.Write(&b, le, []uint16{1, uint16(len(names))})
+			binary.Write(&b, le, []uint32{str(file), 16, next})
+			for j, name := range names {
+				next := uint32(16)
+				if j == len(names)-1 {
+					next = 0
+				}
+				binary.Write(&b, le, []uint32{0})
+				binary.Write(&b, le, []uint16{0, uint16(j + 2)})
+				binary.Write(&b, le, []uint32{str(name), next})
+			}
+		}
+		sections = append(sections, section{name: ".gnu.version_r", typ: elf.SHT_GNU_VERNEED, data: b.Bytes(), link: 1, info: uint32(len(files))})
+	}
+
+	if len(te.verdef) > 0 {
+		var b bytes.Buffer
+		names := append([]string{te.soname}, te.verdef...)
+		for i, name := range names {
+			flags, next := uint16(0), uint32(28)
+			if i == 0 {
+				flags = elfVerFlagBase
+			}
+			if i == len(names)-1 {
+				next = 0
+			}
+			binary.Write(&b, le, []uint16{1, flags, uint16(i + 1), 1})
+			binary.Write(&b, le, []uint32{0, 20, next})
+			binary.Write(&b, le, []uint32{str(name), 0})
+		}
+		sections = append(sections, section{name: ".gnu.version_d", typ: elf.SHT_GNU_VERDEF, data: b.Bytes(), link: 1, info: uint32(len(names))})
+	}
+
+	if te.gnuHash {
+		sections = append(sections, section{name: ".gnu.hash", typ: elf.SHT_GNU_HASH, data: make([]byte, 16), link: 1})
+	}
+
+	var shstrs bytes.Buffer
+	shstrs.WriteByte(0)
+	shname := func(s string) uint32 {
+		off := uint32(shstrs.Len())
+		shstrs.WriteString(s + "\x00")
+		return off
+	}
+	sections = append([]section{{}, {name: ".dynstr", typ: elf.SHT_STRTAB, data: strs.Bytes()}}, sections...)
+	sections = append(sections, section{name: ".shstrtab", typ: elf.SHT_STRTAB})
+	names := make([]uint32, len(sections))
+	for i, s := range sections[1:] {
+		names[i+1] = shname(s.name)
+	}
+	sections[len(sections)-1].data = shstrs.Bytes()
+
+	headerSize := 52
+	if te.class == elf.ELFCLASS64 {
+		headerSize = 64
+	}
+	var body bytes.Buffer
+	offsets := make([]int, len(sections))
+	for i, s := range sections {
+		for body.Len()%8 != 0 {
+			body.WriteByte(0)
+		}
+		offsets[i] = headerSize + body.Len()
+		body.Write(s.data)
+	}
+	for body.Len()%8 != 0 {
+		body.WriteByte(0)
+	}
+	shoff := headerSize + body.Len()
+
+	var out bytes.Buffer
+	ident := [elf.EI_NIDENT]byte{0x7f, 'E', 'L', 'F', byte(te.class), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)}
+	if te.class == elf.ELFCLASS64 {
+		binary.Write(&out, le, elf.Header64{
+			Ident: ident, Type: uint16(te.typ), Machine: uint16(elf.EM_X86_64), Version: uint32(elf.EV_CURRENT),
+			Shoff: uint64(shoff), Ehsize: 64, Shentsize: 64, Shnum: uint16(len(sections)), Shstrndx: uint16(len(sections) - 1),
+		})
+	} else {
+		binary.Write(&out, le, elf.Header32{
+			Ident: ident, Type: uint16(te.typ), Machine: uint16(elf.EM_386), Version: uint32(elf.EV_CURRENT),
+			Shoff: uint32(shoff), Ehsize: 52, Shentsize: 40, Shnum: uint16(len(sections)), Shstrndx: uint16(len(sections) - 1),
+		})
+	}
+	out.Write(body.Bytes())
+	for i, s := range sections {
+		if i == 0 {
+			out.Write(make([]byte, map[elf.Class]int{elf.ELFCLASS32: 40, elf.ELFCLASS64: 64}[te.class]))
+			continue
+		}
+		if te.class == elf.ELFCLASS64 {
+			binary.Write(&out, le, elf.Section64{
+				Name: names[i], Type: uint32(s.typ), Off: uint64(offsets[i]), Size: uint64(len(s.data)),
+				Link: s.link, Info: s.info, Addralign: 1,
+			})
+		} else {
+			binary.Write(&out, le, elf.Section32{
+				Name: names[i], Type: uint32(s.typ), Off: uint32(offsets[i]), Size: uint32(len(s.data)),
+				Link: s.link, Info: s.info, Addralign: 1,
+			})
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"sort"
+	"strings"
+)
+
+// localeDir holds the message catalogs, one directory per language.
+const localeDir = "/usr/share/locale/"
+
+// Translation is the package metadata translated to one locale. Empty fields
+// fall back to the untranslated value.
+type Translation struct {
+	Summary,
+	Description,
+	Group string
+}
+
+// lang returns the language of the file. Without an explicit Lang, files in
+// the message catalogs get the language of their directory, like rpm's
+// find-lang does: `/usr/share/locale/pt_BR/LC_MESSAGES/app.mo` is `pt`.
+func (f RPMFile) lang() string {
+	if f.Lang != "" || f.Mode&modeTypeMask == modeDir {
+		return f.Lang
+	}
+	rel, ok := strings.CutPrefix(f.Name, localeDir)
+	if !ok {
+		return ""
+	}
+	dir, _, ok := strings.Cut(rel, "/")
+	if !ok {
+		return ""
+	}
+	lang, _, _ := strings.Cut(dir, "_")
+	return lang
+}
+
+// i18nTable returns the locales of the i18n table, "C" first and then the
+// translations in sorted order.
+func (r *RPM) i18nTable() []string {
+	locales := make([]string, 0, len(r.Translations))
+	for l := range r.Translations {
+		if l != "C" {
+			locales = append(locales, l)
+		}
+	}
+	sort.Strings(locales)
+	return append([]string{"C"}, locales...)
+}
+
+// addI18NToIndex adds the translated metadata to the index, one value per
+// locale of the table.
+func (r *RPM) addI18NToIndex(h *index) {
+	table := r.i18nTable()
+	summaries := make([]string, len(table))
+	descriptions := make([]string, len(table))
+	groups := make([]string, len(table))
+	summaries[0], descriptions[0], groups[0] = r.Summary, r.Description, r.Group
+	for i, l := range table[1:] {
+		t := r.Translations[l]
+		summaries[i+1], descriptions[i+1], groups[i+1] = t.Summary, t.Description, t.Group
+	}
+	h.Add(tagHeaderI18NTable, EntryStringSlice(table))
+	h.Add(tagSummary, EntryI18NStrings(summaries))
+	h.Add(tagDescription, EntryI18NStrings(descriptions))
+	h.Add(tagGroup, EntryI18NStrings(groups))
+}
+
+// toI18NStrings reads an entry which can hold one value per locale. Some
+// packages use plain strings for the i18n table and the translatable tags.
+func toI18NStrings(e IndexEntry) ([]string, error) {
+	if e.rpmtype == typeString {
+		s, err := e.toString()
+		return []string{s}, err
+	}
+	return e.toStringArray()
+}
+
+// readI18N reads the translated metadata from the header.
+func readI18N(out *RPM) {
+	table, err := popTag(out.headers.entries, tagHeaderI18NTable, toI18NStrings)
+	if err != nil || len(table) == 0 {
+		table = []string{"C"}
+	}
+	summaries, _ := popTag(out.headers.entries, tagSummary, toI18NStrings)
+	descriptions, _ := popTag(out.headers.entries, tagDescription, toI18NStrings)
+	groups, _ := popTag(out.headers.entries, tagGroup, toI18NStrings)
+	at := func(values []string, i int) string {
+		if i < len(values) {
+			return values[i]
+		}
+		return ""
+	}
+	out.Summary, out.Description, out.Group = at(summaries, 0), at(descriptions, 0), at(groups, 0)
+	for i := 1; i < len(table); i++ {
+		t := Translation{
+			Summary:     at(summaries, i),
+			Description: at(descriptions, i),
+			Group:       at(groups, i),
+		}
+		if t == (Translation{}) {
+			continue
+		}
+		if out.Translations == nil {
+			out.Translations = map[string]Translation{}
+		}
+		out.Translations[table[i]] = t
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileLang(t *testing.T) {
+	testCases := []struct {
+		file RPMFile
+		want string
+	}{{
+		file: RPMFile{Name: "/usr/share/locale/de/LC_MESSAGES/app.mo"},
+		want: "de",
+	}, {
+		file: RPMFile{Name: "/usr/share/locale/pt_BR/LC_MESSAGES/app.mo"},
+		want: "pt",
+	}, {
+		file: RPMFile{Name: "/usr/share/locale/sr@latin/LC_MESSAGES/app.mo"},
+		want: "sr@latin",
+	}, {
+		file: RPMFile{Name: "/usr/share/locale/de", Mode: 040755},
+		want: "",
+	}, {
+		file: RPMFile{Name: "/usr/share/locale/de/LC_MESSAGES", Mode: 040755},
+		want: "",
+	}, {
+		file: RPMFile{Name: "/usr/share/locale/locale.alias"},
+		want: "",
+	}, {
+		file: RPMFile{Name: "/usr/share/app/help.fr.html", Lang: "fr"},
+		want: "fr",
+	}, {
+		file: RPMFile{Name: "/usr/share/locale/de/LC_MESSAGES/app.mo", Lang: "de_AT"},
+		want: "de_AT",
+	}, {
+		file: RPMFile{Name: "/usr/bin/app"},
+		want: "",
+	}}
+	for _, tc := range testCases {
+		if got := tc.file.lang(); got != tc.want {
+			t.Errorf("lang() of %s = %q, want %q", tc.file.Name, got, tc.want)
+		}
+	}
+}
+
+func TestI18NIndex(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{
+		Summary:     "An app",
+		Description: "An app doing things.",
+		Group:       "Applications/System",
+		Translations: map[string]Translation{
+			"fr": {Summary: "Une application"},
+			"de": {Summary: "Eine Anwendung", Description: "Eine Anwendung, die Dinge tut."},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	h := newIndex(immutable)
+	r.addI18NToIndex(h)
+	want := map[int]IndexEntry{
+		tagHeaderI18NTable: EntryStringSlice([]string{"C", "de", "fr"}),
+		tagSummary:         EntryI18NStrings([]string{"An app", "Eine Anwendung", "Une application"}),
+		tagDescription:     EntryI18NStrings([]string{"An app doing things.", "Eine Anwendung, die Dinge tut.", ""}),
+		tagGroup:           EntryI18NStrings([]string{"Applications/System", "", ""}),
+	}
+	if d := cmp.Diff(want, h.entries, cmp.AllowUnexported(IndexEntry{})); d != "" {
+		t.Errorf("index differs (want->got):\n%v", d)
+	}
+}
+
+func TestI18NRoundTrip(t *testing.T) {
+	md := RPMMetaData{
+		Name:        "app",
+		Summary:     "An app",
+		Description: "An app doing things.",
+		Group:       "Applications/System",
+		Translations: map[string]Translation{
+			"de":    {Summary: "Eine Anwendung", Group: "Anwendungen/System"},
+			"pt_BR": {Description: "Um aplicativo que faz coisas."},
+		},
+	}
+	r, err := NewRPM(md)
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/usr/bin/app", Body: []byte("binary")})
+	r.AddFile(RPMFile{Name: "/usr/share/locale/de/LC_MESSAGES/app.mo", Body: []byte("de")})
+	r.AddFile(RPMFile{Name: "/usr/share/app/help.fr.html", Body: []byte("fr"), Lang: "fr"})
+	r.AllowListDirs(map[string]bool{})
+
+	f, err := os.CreateTemp(t.TempDir(), "i18n-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	if got.Summary != md.Summary || got.Description != md.Description || got.Group != md.Group {
+		t.Errorf("got metadata %q %q %q, want %q %q %q", got.Summary, got.Description, got.Group, md.Summary, md.Description, md.Group)
+	}
+	if d := cmp.Diff(md.Translations, got.Translations); d != "" {
+		t.Errorf("translations differ (want->got):\n%v", d)
+	}
+	if d := cmp.Diff([]string{"", "fr", "de"}, got.filelangs); d != "" {
+		t.Errorf("file langs differ (want->got):\n%v", d)
+	}
+	if l := got.files["/usr/share/locale/de/LC_MESSAGES/app.mo"].Lang; l != "de" {
+		t.Errorf("message catalog lang = %q, want de", l)
+	}
+}
+
+func TestReadI18NPlainStrings(t *testing.T) {
+	h := newIndex(immutable)
+	h.Add(tagHeaderI18NTable, EntryString("C"))
+	h.Add(tagSummary, EntryString("An app"))
+	h.Add(tagDescription, EntryString("An app doing things."))
+	out := &RPM{headers: h}
+	readI18N(out)
+	if out.Summary != "An app" || out.Description != "An app doing things." || out.Group != "" {
+		t.Errorf("got metadata %q %q %q", out.Summary, out.Description, out.Group)
+	}
+	if out.Translations != nil {
+		t.Errorf("got unexpected translations %v", out.Translations)
+	}
+	if len(h.entries) != 0 {
+		t.Errorf("readI18N left entries %v", h.entries)
+	}
+}
@@ -0,0 +1,434 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest is a declarative description of a package, read from YAML or
+// JSON by ParseManifest. Dependencies are written like on the command line,
+// e.g. `bash >= 5`, and Dependencies takes spec file lines like
+// `Requires(post): systemd`. There is no separate schema file: the fields of
+// Manifest and its nested types are the schema, which ParseManifest checks.
+type Manifest struct {
+	Name         string                 `yaml:"name"`
+	Version      string                 `yaml:"version"`
+	Release      string                 `yaml:"release"`
+	Epoch        uint32                 `yaml:"epoch"`
+	Arch         string                 `yaml:"arch"`
+	OS           string                 `yaml:"os"`
+	Summary      string                 `yaml:"summary"`
+	Description  string                 `yaml:"description"`
+	Vendor       string                 `yaml:"vendor"`
+	Packager     string                 `yaml:"packager"`
+	Group        string                 `yaml:"group"`
+	URL          string                 `yaml:"url"`
+	Licence      string                 `yaml:"licence"`
+	Compressor   string                 `yaml:"compressor"`
+	Digest       string                 `yaml:"digest"`
+	BuildTime    int64                  `yaml:"build_time"`
+	Reproducible bool                   `yaml:"reproducible"`
+	Prefixes     []string               `yaml:"prefixes"`
+	Translations map[string]Translation `yaml:"translations"`
+	ParentDirs   []string               `yaml:"parent_dirs"`
+
+	Provides          []string `yaml:"provides"`
+	Obsoletes         []string `yaml:"obsoletes"`
+	Suggests          []string `yaml:"suggests"`
+	Recommends        []string `yaml:"recommends"`
+	Requires          []string `yaml:"requires"`
+	Conflicts         []string `yaml:"conflicts"`
+	OrderWithRequires []string `yaml:"order_with_requires"`
+	Supplements       []string `yaml:"supplements"`
+	Enhances          []string `yaml:"enhances"`
+	Dependencies      []string `yaml:"dependencies"`
+
+	// Scripts are keyed by scriptlet: pretrans, prein, postin, preun,
+	// postun, posttrans or verifyscript.
+	Scripts           map[string]ManifestScript `yaml:"scripts"`
+	Triggers          []ManifestTrigger         `yaml:"triggers"`
+	FileTriggers      []ManifestFileTrigger     `yaml:"file_triggers"`
+	TransFileTriggers []ManifestFileTrigger     `yaml:"trans_file_triggers"`
+	Files             []ManifestFileRule        `yaml:"files"`
+	Changelog         []ManifestChangelogEntry  `yaml:"changelog"`
+
+	metadata          RPMMetaData
+	triggers          []Trigger
+	fileTriggers      []FileTrigger
+	transFileTriggers []FileTrigger
+	fileRules         []fileRule
+}
+
+// ManifestScript is a scriptlet of a manifest.
+type ManifestScript struct {
+	Body string `yaml:"body"`
+	// Interpreter and its arguments, e.g. `/usr/bin/bash -e` or `<lua>`.
+	Interpreter string `yaml:"interpreter"`
+}
+
+// ManifestTrigger is a trigger of a manifest.
+type ManifestTrigger struct {
+	// Type is one of prein, in, un or postun.
+	Type        string   `yaml:"type"`
+	Conditions  []string `yaml:"conditions"`
+	Body        string   `yaml:"body"`
+	Interpreter string   `yaml:"interpreter"`
+}
+
+// ManifestFileTrigger is a file trigger of a manifest.
+type ManifestFileTrigger struct {
+	// Type is one of in, un or postun.
+	Type        string   `yaml:"type"`
+	Prefixes    []string `yaml:"prefixes"`
+	Priority    uint32   `yaml:"priority"`
+	Body        string   `yaml:"body"`
+	Interpreter string   `yaml:"interpreter"`
+}
+
+// ManifestFileRule sets attributes of the files matching Path, a glob as
+// used by path.Match. When several rules match a file, the later ones win
+// for every attribute they set.
+type ManifestFileRule struct {
+	Path string `yaml:"path"`
+	// Mode holds the permission bits in octal, e.g. `0644`.
+	Mode  string `yaml:"mode"`
+	Owner string `yaml:"owner"`
+	Group string `yaml:"group"`
+	// Type lists file types: config, noreplace, doc, missingok, ghost,
+	// license or readme.
+	Type []string `yaml:"type"`
+	// NoVerify skips checks of rpm -V, like %verify: `not md5 size mtime`.
+	NoVerify     string `yaml:"verify"`
+	Capabilities string `yaml:"caps"`
+	Lang         string `yaml:"lang"`
+}
+
+// ManifestChangelogEntry is a changelog entry of a manifest.
+type ManifestChangelogEntry struct {
+	// Date is either `2006-01-02` or RFC 3339.
+	Date   string `yaml:"date"`
+	Author string `yaml:"author"`
+	Text   string `yaml:"text"`
+}
+
+// ManifestFieldError is an invalid field of a manifest.
+type ManifestFieldError struct {
+	// Field is the path of the field, e.g. `files[2].mode`.
+	Field string
+	Err   error
+}
+
+func (e *ManifestFieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *ManifestFieldError) Unwrap() error {
+	return e.Err
+}
+
+// fileRule is a compiled ManifestFileRule.
+type fileRule struct {
+	ManifestFileRule
+	mode     uint
+	hasMode  bool
+	fileType FileType
+	noVerify VerifyFlags
+}
+
+var manifestFileTypes = map[string]FileType{
+	"config":    ConfigFile,
+	"noreplace": ConfigFile | NoReplaceFile,
+	"doc":       DocFile,
+	"missingok": MissingOkFile,
+	"ghost":     GhostFile,
+	"license":   LicenceFile,
+	"readme":    ReadmeFile,
+}
+
+var manifestTriggerTypes = map[string]rpmSense{
+	"prein":  TriggerPrein,
+	"in":     TriggerIn,
+	"un":     TriggerUn,
+	"postun": TriggerPostun,
+}
+
+var scriptletAdders = map[string]func(r *RPM, s string, interpreter ...string){
+	"pretrans":     (*RPM).AddPretrans,
+	"prein":        (*RPM).AddPrein,
+	"postin":       (*RPM).AddPostin,
+	"preun":        (*RPM).AddPreun,
+	"postun":       (*RPM).AddPostun,
+	"posttrans":    (*RPM).AddPosttrans,
+	"verifyscript": (*RPM).AddVerifyScript,
+}
+
+// ParseManifest reads a YAML or JSON manifest. Unknown fields are errors,
+// and every invalid field is reported as a ManifestFieldError.
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(true)
+	if err := d.Decode(m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if err := m.compile(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// compile validates the manifest and converts it to the package types.
+func (m *Manifest) compile() error {
+	var errs []error
+	fail := func(field string, err error) {
+		errs = append(errs, &ManifestFieldError{Field: field, Err: err})
+	}
+
+	if m.Name == "" {
+		fail("name", errors.New("is required"))
+	}
+	if m.Version == "" {
+		fail("version", errors.New("is required"))
+	}
+	if _, _, err := setupDigest(m.Digest); err != nil {
+		fail("digest", err)
+	}
+	md := RPMMetaData{
+		Name:            m.Name,
+		Version:         m.Version,
+		Release:         m.Release,
+		Epoch:           m.Epoch,
+		Arch:            m.Arch,
+		OS:              m.OS,
+		Summary:         m.Summary,
+		Description:     m.Description,
+		Vendor:          m.Vendor,
+		Packager:        m.Packager,
+		Group:           m.Group,
+		URL:             m.URL,
+		Licence:         m.Licence,
+		Compressor:      m.Compressor,
+		DigestAlgorithm: m.Digest,
+		Reproducible:    m.Reproducible,
+		Prefixes:        m.Prefixes,
+		Translations:    m.Translations,
+	}
+	if m.BuildTime != 0 {
+		md.BuildTime = time.Unix(m.BuildTime, 0)
+	}
+
+	for _, c := range []struct {
+		field string
+		deps  []string
+		rels  *Relations
+	}{
+		{"provides", m.Provides, &md.Provides},
+		{"obsoletes", m.Obsoletes, &md.Obsoletes},
+		{"suggests", m.Suggests, &md.Suggests},
+		{"recommends", m.Recommends, &md.Recommends},
+		{"requires", m.Requires, &md.Requires},
+		{"conflicts", m.Conflicts, &md.Conflicts},
+		{"order_with_requires", m.OrderWithRequires, &md.OrderWithRequires},
+		{"supplements", m.Supplements, &md.Supplements},
+		{"enhances", m.Enhances, &md.Enhances},
+	} {
+		for i, dep := range c.deps {
+			if err := c.rels.Set(dep); err != nil {
+				fail(fmt.Sprintf("%s[%d]", c.field, i), err)
+			}
+		}
+	}
+	for i, line := range m.Dependencies {
+		if err := md.AddDependencies(line); err != nil {
+			fail(fmt.Sprintf("dependencies[%d]", i), err)
+		}
+	}
+
+	for i, e := range m.Changelog {
+		field := fmt.Sprintf("changelog[%d]", i)
+		valid := true
+		t, err := time.Parse("2006-01-02", e.Date)
+		if err != nil {
+			if t, err = time.Parse(time.RFC3339, e.Date); err != nil {
+				fail(field+".date", fmt.Errorf("%q is neither 2006-01-02 nor RFC 3339", e.Date))
+				valid = false
+			}
+		}
+		if e.Author == "" {
+			fail(field+".author", errors.New("is required"))
+			valid = false
+		}
+		if !valid {
+			continue
+		}
+		md.Changelog = append(md.Changelog, &ChangelogEntry{Time: uint32(t.Unix()), Name: e.Author, Text: e.Text})
+	}
+
+	scripts := make([]string, 0, len(m.Scripts))
+	for name := range m.Scripts {
+		scripts = append(scripts, name)
+	}
+	sort.Strings(scripts)
+	for _, name := range scripts {
+		if _, ok := scriptletAdders[name]; !ok {
+			fail("scripts."+name, errors.New("unknown scriptlet, want one of pretrans, prein, postin, preun, postun, posttrans or verifyscript"))
+		}
+	}
+
+	for i, mt := range m.Triggers {
+		field := fmt.Sprintf("triggers[%d]", i)
+		t := Trigger{Type: manifestTriggerTypes[mt.Type], Script: NewScriptlet(mt.Body, strings.Fields(mt.Interpreter)...)}
+		if t.Type == SenseAny {
+			fail(field+".type", fmt.Errorf("%q is not one of prein, in, un or postun", mt.Type))
+			continue
+		}
+		for j, c := range mt.Conditions {
+			if err := t.Conditions.Set(c); err != nil {
+				fail(fmt.Sprintf("%s.conditions[%d]", field, j), err)
+			}
+		}
+		if err := t.validate(); err != nil {
+			fail(field, err)
+			continue
+		}
+		m.triggers = append(m.triggers, t)
+	}
+
+	for _, family := range []struct {
+		field    string
+		triggers []ManifestFileTrigger
+		out      *[]FileTrigger
+	}{
+		{"file_triggers", m.FileTriggers, &m.fileTriggers},
+		{"trans_file_triggers", m.TransFileTriggers, &m.transFileTriggers},
+	} {
+		for i, mt := range family.triggers {
+			field := fmt.Sprintf("%s[%d]", family.field, i)
+			t := FileTrigger{
+				Type:     manifestTriggerTypes[mt.Type],
+				Prefixes: mt.Prefixes,
+				Priority: mt.Priority,
+				Script:   NewScriptlet(mt.Body, strings.Fields(mt.Interpreter)...),
+			}
+			if err := t.validate(); err != nil {
+				fail(field, err)
+				continue
+			}
+			*family.out = append(*family.out, t)
+		}
+	}
+
+	for i, mr := range m.Files {
+		field := fmt.Sprintf("files[%d]", i)
+		rule := fileRule{ManifestFileRule: mr}
+		if _, err := path.Match(mr.Path, ""); err != nil || mr.Path == "" {
+			fail(field+".path", fmt.Errorf("invalid pattern %q", mr.Path))
+		}
+		if mr.Mode != "" {
+			mode, err := strconv.ParseUint(mr.Mode, 8, 32)
+			if err != nil || mode&^07777 != 0 {
+				fail(field+".mode", fmt.Errorf("%q is not an octal permission mode", mr.Mode))
+			}
+			rule.mode, rule.hasMode = uint(mode), true
+		}
+		for j, name := range mr.Type {
+			t, ok := manifestFileTypes[name]
+			if !ok {
+				fail(fmt.Sprintf("%s.type[%d]", field, j), fmt.Errorf("unknown file type %q", name))
+			}
+			rule.fileType |= t
+		}
+		if mr.NoVerify != "" {
+			noVerify, err := ParseNoVerify(mr.NoVerify)
+			if err != nil {
+				fail(field+".verify", err)
+			}
+			rule.noVerify = noVerify
+		}
+		m.fileRules = append(m.fileRules, rule)
+	}
+
+	m.metadata = md
+	return errors.Join(errs...)
+}
+
+// Metadata returns the package metadata of the manifest.
+func (m *Manifest) Metadata() RPMMetaData {
+	return m.metadata
+}
+
+// Apply adds the scriptlets and triggers of the manifest to the package,
+// sets the attributes of its files, and sets up its parent directories.
+func (m *Manifest) Apply(r *RPM) error {
+	for name, s := range m.Scripts {
+		scriptletAdders[name](r, s.Body, strings.Fields(s.Interpreter)...)
+	}
+	for _, t := range m.triggers {
+		if err := r.AddTrigger(t); err != nil {
+			return err
+		}
+	}
+	for _, t := range m.fileTriggers {
+		if err := r.AddFileTrigger(t); err != nil {
+			return err
+		}
+	}
+	for _, t := range m.transFileTriggers {
+		if err := r.AddTransFileTrigger(t); err != nil {
+			return err
+		}
+	}
+	for fn, f := range r.files {
+		for _, rule := range m.fileRules {
+			if ok, _ := path.Match(rule.Path, fn); !ok {
+				continue
+			}
+			if rule.hasMode {
+				f.Mode = f.Mode&^07777 | rule.mode
+			}
+			if rule.Owner != "" {
+				f.Owner = rule.Owner
+			}
+			if rule.Group != "" {
+				f.Group = rule.Group
+			}
+			f.Type |= rule.fileType
+			if rule.NoVerify != "" {
+				f.NoVerify = rule.noVerify
+			}
+			if rule.Capabilities != "" {
+				f.Capabilities = rule.Capabilities
+			}
+			if rule.Lang != "" {
+				f.Lang = rule.Lang
+			}
+		}
+		r.files[fn] = f
+	}
+	if len(m.ParentDirs) > 0 {
+		r.SetParentDirs(ParentDirs{Roots: m.ParentDirs})
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testManifest = `
+name: foo
+version: "1.2"
+release: "3"
+epoch: 1
+arch: x86_64
+summary: The foo tool
+licence: MIT
+requires:
+  - bash >= 5
+dependencies:
+  - "Requires(post): systemd"
+scripts:
+  postin:
+    body: systemctl daemon-reload
+    interpreter: /bin/sh -e
+triggers:
+  - type: in
+    conditions: [bar]
+    body: echo bar
+files:
+  - path: /etc/foo/*
+    mode: "0640"
+    type: [config, noreplace]
+  - path: /etc/foo/secret
+    owner: foo
+    group: foo
+    mode: "0600"
+changelog:
+  - date: 2024-01-02
+    author: Jane Doe <jane@example.com> - 1.2-3
+    text: "- Release 1.2"
+`
+
+func TestParseManifest(t *testing.T) {
+	testCases := []struct {
+		name, input string
+	}{
+		{"yaml", testManifest},
+		{"json", `{
+			"name": "foo", "version": "1.2", "release": "3", "epoch": 1,
+			"arch": "x86_64", "summary": "The foo tool", "licence": "MIT",
+			"requires": ["bash >= 5"],
+			"dependencies": ["Requires(post): systemd"],
+			"changelog": [{"date": "2024-01-02T00:00:00Z", "author": "Jane Doe <jane@example.com> - 1.2-3", "text": "- Release 1.2"}]
+		}`},
+	}
+	want := RPMMetaData{
+		Name:    "foo",
+		Version: "1.2",
+		Release: "3",
+		Epoch:   1,
+		Arch:    "x86_64",
+		Summary: "The foo tool",
+		Licence: "MIT",
+		Requires: Relations{
+			{Name: "bash", Version: "5", Sense: SenseGreater | SenseEqual},
+			{Name: "systemd", Sense: ScriptPost},
+		},
+		Changelog: Changelog{
+			{Time: 1704153600, Name: "Jane Doe <jane@example.com> - 1.2-3", Text: "- Release 1.2"},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := ParseManifest([]byte(tc.input))
+			if err != nil {
+				t.Fatalf("ParseManifest returned err: %v", err)
+			}
+			if d := cmp.Diff(want, m.Metadata()); d != "" {
+				t.Errorf("Metadata differs (want->got):\n%v", d)
+			}
+		})
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	testCases := []struct {
+		name, input string
+		wantFields  []string
+	}{{
+		name:       "required",
+		input:      `summary: foo`,
+		wantFields: []string{"name", "version"},
+	}, {
+		name: "fields",
+		input: `
+name: foo
+version: "1"
+digest: md4
+requires: ["(bash or", "sh"]
+scripts:
+  postinstall: {body: "true"}
+triggers:
+  - {type: after, conditions: [bar]}
+files:
+  - {path: /etc/*, mode: "0999"}
+  - {path: "/etc/[", type: [conf]}
+changelog:
+  - {date: yesterday}
+`,
+		wantFields: []string{
+			"digest",
+			"requires[0]",
+			"changelog[0].date",
+			"changelog[0].author",
+			"scripts.postinstall",
+			"triggers[0].type",
+			"files[0].mode",
+			"files[1].path",
+			"files[1].type[0]",
+		},
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseManifest([]byte(tc.input))
+			if err == nil {
+				t.Fatal("ParseManifest returned no error")
+			}
+			var fields []string
+			for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+				var fe *ManifestFieldError
+				if !errors.As(e, &fe) {
+					t.Fatalf("got %T, want *ManifestFieldError: %v", e, e)
+				}
+				fields = append(fields, fe.Field)
+			}
+			if d := cmp.Diff(tc.wantFields, fields); d != "" {
+				t.Errorf("invalid fields differ (want->got):\n%v", d)
+			}
+		})
+	}
+}
+
+func TestParseManifestUnknownField(t *testing.T) {
+	_, err := ParseManifest([]byte("name: foo\nversion: \"1\"\nlicense: MIT\n"))
+	if err == nil || !strings.Contains(err.Error(), "license") {
+		t.Errorf("ParseManifest want an error about license, got %v", err)
+	}
+}
+
+func TestManifestInvalidChangelog(t *testing.T) {
+	m := &Manifest{
+		Name:    "foo",
+		Version: "1",
+		Changelog: []ManifestChangelogEntry{
+			{Date: "yesterday", Author: "Jane Doe", Text: "- Broken date"},
+			{Date: "2024-01-02", Text: "- No author"},
+		},
+	}
+	if err := m.compile(); err == nil {
+		t.Fatal("compile returned no error")
+	}
+	if len(m.metadata.Changelog) != 0 {
+		t.Errorf("compile added invalid changelog entries %v", m.metadata.Changelog)
+	}
+}
+
+func TestManifestApply(t *testing.T) {
+	m, err := ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatalf("ParseManifest returned err: %v", err)
+	}
+
+	b := &bytes.Buffer{}
+	ta := tar.NewWriter(b)
+	for _, name := range []string{"etc/foo/foo.conf", "etc/foo/secret", "usr/bin/foo"} {
+		if err := ta.WriteHeader(&tar.Header{Name: name, Mode: 0755, Uname: "root", Gname: "root"}); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+	}
+	ta.Close()
+
+	r, err := FromTar(b, m.Metadata())
+	if err != nil {
+		t.Fatalf("FromTar returned err: %v", err)
+	}
+	if err := m.Apply(r); err != nil {
+		t.Fatalf("Apply returned err: %v", err)
+	}
+	f, err := os.CreateTemp(t.TempDir(), "manifest.rpm")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer f.Close()
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned err: %v", err)
+	}
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned err: %v", err)
+	}
+
+	type attrs struct {
+		Mode         uint
+		Owner, Group string
+		Type         FileType
+	}
+	want := map[string]attrs{
+		"/etc/foo/foo.conf": {0100640, "root", "root", ConfigFile | NoReplaceFile},
+		"/etc/foo/secret":   {0100600, "foo", "foo", ConfigFile | NoReplaceFile},
+		"/usr/bin/foo":      {0100755, "root", "root", 0},
+	}
+	files := map[string]attrs{}
+	for name, f := range got.files {
+		files[name] = attrs{f.Mode, f.Owner, f.Group, f.Type}
+	}
+	if d := cmp.Diff(want, files); d != "" {
+		t.Errorf("file attributes differ (want->got):\n%v", d)
+	}
+	if d := cmp.Diff(NewScriptlet("systemctl daemon-reload", "/bin/sh", "-e"), got.postin); d != "" {
+		t.Errorf("postin differs (want->got):\n%v", d)
+	}
+	if len(got.triggers) != 1 || got.triggers[0].Type != TriggerIn {
+		t.Errorf("want one trigger on install, got %+v", got.triggers)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrUnroutedFile is returned when no package of a set takes a file.
+	ErrUnroutedFile = errors.New("no package for file")
+	// ErrDuplicatePackage is returned when a set already has a package with
+	// the same suffix.
+	ErrDuplicatePackage = errors.New("duplicate package")
+	// ErrUnknownSibling is returned when a package requires a sibling which
+	// is not in the set.
+	ErrUnknownSibling = errors.New("unknown sibling package")
+	// ErrCrossPackageHardLink is returned when a hard link and its target
+	// are routed to different packages.
+	ErrCrossPackageHardLink = errors.New("hard link to a file of another package")
+)
+
+// Subpackage configures a package of a PackageSet.
+type Subpackage struct {
+	// Suffix names the package after the base name, e.g. `devel` for
+	// foo-devel. The empty suffix is the base package itself.
+	Suffix string
+	// Paths route files to the package. They are glob patterns as used by
+	// path.Match, or directories which take everything below them, e.g.
+	// `/usr/include`. The first package with a matching path takes a file,
+	// and the package without paths takes the files no other package does.
+	Paths []string
+	// Metadata is specific to the package. Summary, Description, Group,
+	// Arch, URL, Licence, Compressor, DigestAlgorithm, Prefixes and
+	// Translations replace the common ones when set, Reproducible applies
+	// when either sets it, and relations are added to the common ones.
+	// Everything else, like the name, version, release, epoch and
+	// changelog, is always shared.
+	Metadata RPMMetaData
+	// RequiresSiblings are the suffixes of the packages this package
+	// requires at exactly the same version.
+	RequiresSiblings []string
+	// Standalone packages don't require the base package. Every other
+	// package does, when the set has one.
+	Standalone bool
+}
+
+// PackageSet builds several packages from one definition, like the
+// subpackages of a spec file.
+type PackageSet struct {
+	common   RPMMetaData
+	subs     []Subpackage
+	packages []*RPM
+	bodies   *spool
+}
+
+// NewPackageSet creates a set of packages sharing the common metadata. The
+// common name is the name of the base package.
+func NewPackageSet(common RPMMetaData) *PackageSet {
+	return &PackageSet{common: common}
+}
+
+// packageName returns the name of the package with the suffix.
+func (s *PackageSet) packageName(suffix string) string {
+	if suffix == "" {
+		return s.common.Name
+	}
+	return s.common.Name + "-" + suffix
+}
+
+// merge returns the metadata of a subpackage.
+func (s *PackageSet) merge(sub Subpackage) RPMMetaData {
+	m := s.common
+	o := sub.Metadata
+	m.Name = s.packageName(sub.Suffix)
+	for _, f := range []struct{ dst, src *string }{
+		{&m.Summary, &o.Summary},
+		{&m.Description, &o.Description},
+		{&m.Group, &o.Group},
+		{&m.Arch, &o.Arch},
+		{&m.URL, &o.URL},
+		{&m.Licence, &o.Licence},
+		{&m.Compressor, &o.Compressor},
+		{&m.DigestAlgorithm, &o.DigestAlgorithm},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if o.Prefixes != nil {
+		m.Prefixes = o.Prefixes
+	}
+	if o.Translations != nil {
+		m.Translations = o.Translations
+	}
+	m.Reproducible = m.Reproducible || o.Reproducible
+	// Every package comes from the same source rpm.
+	if m.SourcePackage == "" {
+		m.SourcePackage = s.common.Name + "-" + s.common.Version
+		if s.common.Release != "" {
+			m.SourcePackage += "-" + s.common.Release
+		}
+		m.SourcePackage += ".src.rpm"
+	}
+	add := func(common, own Relations) Relations {
+		rels := append(Relations{}, common...)
+		for _, rel := range own {
+			rels.addIfMissing(rel)
+		}
+		return rels
+	}
+	m.Provides = add(s.common.Provides, o.Provides)
+	m.Obsoletes = add(s.common.Obsoletes, o.Obsoletes)
+	m.Suggests = add(s.common.Suggests, o.Suggests)
+	m.Recommends = add(s.common.Recommends, o.Recommends)
+	m.Requires = add(s.common.Requires, o.Requires)
+	m.Conflicts = add(s.common.Conflicts, o.Conflicts)
+	m.OrderWithRequires = add(s.common.OrderWithRequires, o.OrderWithRequires)
+	m.Supplements = add(s.common.Supplements, o.Supplements)
+	m.Enhances = add(s.common.Enhances, o.Enhances)
+	return m
+}
+
+// AddPackage adds a package to the set. The returned RPM can be used to add
+// scriptlets, triggers and files which are not routed by path.
+func (s *PackageSet) AddPackage(sub Subpackage) (*RPM, error) {
+	for _, other := range s.subs {
+		if other.Suffix == sub.Suffix {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicatePackage, s.packageName(sub.Suffix))
+		}
+	}
+	r, err := NewRPM(s.merge(sub))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create package %q: %w", s.packageName(sub.Suffix), err)
+	}
+	s.subs = append(s.subs, sub)
+	s.packages = append(s.packages, r)
+	return r, nil
+}
+
+// matchesPath reports whether the file name matches a path rule.
+func matchesPath(rule, name string) bool {
+	if ok, _ := path.Match(rule, name); ok {
+		return true
+	}
+	dir := strings.TrimSuffix(rule, "/")
+	return strings.HasPrefix(name, dir+"/")
+}
+
+// route returns the package which takes the file.
+func (s *PackageSet) route(name string) (*RPM, error) {
+	var fallback *RPM
+	for i, sub := range s.subs {
+		if len(sub.Paths) == 0 {
+			if fallback == nil {
+				fallback = s.packages[i]
+			}
+			continue
+		}
+		for _, rule := range sub.Paths {
+			if matchesPath(rule, name) {
+				return s.packages[i], nil
+			}
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnroutedFile, name)
+	}
+	return fallback, nil
+}
+
+// AddFile adds the file to the package its path routes it to.
+func (s *PackageSet) AddFile(f RPMFile) error {
+	r, err := s.route(f.Name)
+	if err != nil {
+		return err
+	}
+	r.AddFile(f)
+	return nil
+}
+
+// AddTar adds the entries of a tar file, routed by path. Like FromTar, file
+// bodies are spooled rather than kept in memory.
+func (s *PackageSet) AddTar(inp io.Reader) error {
+	if s.bodies == nil {
+		s.bodies = newSpool(spoolMemLimit)
+	}
+	return readTar(inp, s.bodies, s.AddFile)
+}
+
+// addSiblingRequires adds the requirements between the packages.
+func (s *PackageSet) addSiblingRequires() error {
+	byName := map[string]bool{}
+	for _, sub := range s.subs {
+		byName[sub.Suffix] = true
+	}
+	for i, sub := range s.subs {
+		siblings := sub.RequiresSiblings
+		if sub.Suffix != "" && !sub.Standalone && byName[""] {
+			siblings = append([]string{""}, siblings...)
+		}
+		for _, sibling := range siblings {
+			if !byName[sibling] {
+				return fmt.Errorf("%w: %q requires %q", ErrUnknownSibling, s.packageName(sub.Suffix), s.packageName(sibling))
+			}
+			r := s.packages[i]
+			r.Requires.addIfMissing(&Relation{
+				Name:    s.packageName(sibling),
+				Version: r.EVR().String(),
+				Sense:   SenseEqual,
+			})
+		}
+	}
+	return nil
+}
+
+// checkHardLinks returns an error for hard links whose target was routed to
+// another package, as a link can only be made within a package.
+func (s *PackageSet) checkHardLinks() error {
+	owner := map[string]*RPM{}
+	for _, r := range s.packages {
+		for name := range r.files {
+			owner[name] = r
+		}
+	}
+	for _, r := range s.packages {
+		for name, f := range r.files {
+			if f.HardLink == "" {
+				continue
+			}
+			if o, ok := owner[f.HardLink]; ok && o != r {
+				return fmt.Errorf("%w: %q in %q links to %q in %q", ErrCrossPackageHardLink, name, r.Name, f.HardLink, o.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Write writes every package of the set, in the order they were added.
+// create returns the writer of a package, which is closed once the package
+// is written.
+func (s *PackageSet) Write(create func(r *RPM) (io.WriteCloser, error)) error {
+	defer func() {
+		if s.bodies != nil {
+			s.bodies.Close()
+		}
+	}()
+	if err := s.addSiblingRequires(); err != nil {
+		return err
+	}
+	if err := s.checkHardLinks(); err != nil {
+		return err
+	}
+	for _, r := range s.packages {
+		w, err := create(r)
+		if err != nil {
+			return err
+		}
+		if err := r.Write(w); err != nil {
+			w.Close()
+			return fmt.Errorf("failed to write package %q: %w", r.Name, err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to close package %q: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// WriteDir writes every package of the set to dir, named by RPM.FileName.
+func (s *PackageSet) WriteDir(dir string) error {
+	return s.Write(func(r *RPM) (io.WriteCloser, error) {
+		return os.Create(filepath.Join(dir, r.FileName()))
+	})
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPackageSet(t *testing.T) {
+	s := NewPackageSet(RPMMetaData{
+		Name:    "foo",
+		Version: "1.0",
+		Release: "2",
+		Epoch:   1,
+		Licence: "MIT",
+		Summary: "The foo tool",
+		Arch:    "x86_64",
+	})
+	for _, sub := range []Subpackage{
+		{},
+		{Suffix: "devel", Paths: []string{"/usr/include", "/usr/lib64/*.so"},
+			Metadata: RPMMetaData{Summary: "Headers for foo"}},
+		{Suffix: "doc", Paths: []string{"/usr/share/doc/"}, Standalone: true,
+			Metadata: RPMMetaData{Arch: "noarch"}},
+		{Suffix: "debuginfo", Paths: []string{"/usr/lib/debug"}, RequiresSiblings: []string{"devel"}},
+	} {
+		if _, err := s.AddPackage(sub); err != nil {
+			t.Fatalf("AddPackage(%q) returned err: %v", sub.Suffix, err)
+		}
+	}
+
+	b := &bytes.Buffer{}
+	ta := tar.NewWriter(b)
+	for _, name := range []string{
+		"usr/bin/foo",
+		"usr/lib64/libfoo.so.1",
+		"usr/lib64/libfoo.so",
+		"usr/include/foo/foo.h",
+		"usr/share/doc/foo/README",
+		"usr/lib/debug/usr/bin/foo.debug",
+	} {
+		if err := ta.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(name))}); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if _, err := ta.Write([]byte(name)); err != nil {
+			t.Fatalf("failed to write body: %v", err)
+		}
+	}
+	ta.Close()
+	if err := s.AddTar(b); err != nil {
+		t.Fatalf("AddTar returned err: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := s.WriteDir(dir); err != nil {
+		t.Fatalf("WriteDir returned err: %v", err)
+	}
+
+	testCases := []struct {
+		file         string
+		wantSummary  string
+		wantFiles    []string
+		wantSiblings []string
+	}{{
+		file:        "foo-1.0-2.x86_64.rpm",
+		wantSummary: "The foo tool",
+		wantFiles:   []string{"/usr/bin/foo", "/usr/lib64/libfoo.so.1"},
+	}, {
+		file:         "foo-devel-1.0-2.x86_64.rpm",
+		wantSummary:  "Headers for foo",
+		wantFiles:    []string{"/usr/include/foo/foo.h", "/usr/lib64/libfoo.so"},
+		wantSiblings: []string{"foo"},
+	}, {
+		file:        "foo-doc-1.0-2.noarch.rpm",
+		wantSummary: "The foo tool",
+		wantFiles:   []string{"/usr/share/doc/foo/README"},
+	}, {
+		file:         "foo-debuginfo-1.0-2.x86_64.rpm",
+		wantSummary:  "The foo tool",
+		wantFiles:    []string{"/usr/lib/debug/usr/bin/foo.debug"},
+		wantSiblings: []string{"foo", "foo-devel"},
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.file, func(t *testing.T) {
+			r, err := ReadRPMFile(filepath.Join(dir, tc.file))
+			if err != nil {
+				t.Fatalf("ReadRPMFile returned err: %v", err)
+			}
+			if r.Summary != tc.wantSummary || r.Licence != "MIT" || r.SourcePackage != "foo-1.0-2.src.rpm" {
+				t.Errorf("got summary %q, licence %q, source package %q", r.Summary, r.Licence, r.SourcePackage)
+			}
+			var files []string
+			for name := range r.files {
+				files = append(files, name)
+			}
+			sort.Strings(files)
+			if d := cmp.Diff(tc.wantFiles, files); d != "" {
+				t.Errorf("files differ (want->got):\n%v", d)
+			}
+			var siblings []string
+			for _, rel := range r.Requires {
+				if rel.Version == "1:1.0-2" && rel.Sense == SenseEqual {
+					siblings = append(siblings, rel.Name)
+				}
+			}
+			if d := cmp.Diff(tc.wantSiblings, siblings); d != "" {
+				t.Errorf("sibling requires differ (want->got):\n%v", d)
+			}
+		})
+	}
+}
+
+func TestPackageSetErrors(t *testing.T) {
+	s := NewPackageSet(RPMMetaData{Name: "foo", Version: "1.0"})
+	if _, err := s.AddPackage(Subpackage{Suffix: "devel", Paths: []string{"/usr/include"}}); err != nil {
+		t.Fatalf("AddPackage returned err: %v", err)
+	}
+	if _, err := s.AddPackage(Subpackage{Suffix: "devel"}); !errors.Is(err, ErrDuplicatePackage) {
+		t.Errorf("AddPackage want ErrDuplicatePackage, got %v", err)
+	}
+	if err := s.AddFile(RPMFile{Name: "/usr/bin/foo"}); !errors.Is(err, ErrUnroutedFile) {
+		t.Errorf("AddFile want ErrUnroutedFile, got %v", err)
+	}
+	if _, err := s.AddPackage(Subpackage{Suffix: "static", RequiresSiblings: []string{"libs"}}); err != nil {
+		t.Fatalf("AddPackage returned err: %v", err)
+	}
+	if err := s.WriteDir(t.TempDir()); !errors.Is(err, ErrUnknownSibling) {
+		t.Errorf("WriteDir want ErrUnknownSibling, got %v", err)
+	}
+}
+
+func TestPackageSetCrossPackageHardLink(t *testing.T) {
+	s := NewPackageSet(RPMMetaData{Name: "foo", Version: "1.0"})
+	for _, sub := range []Subpackage{{}, {Suffix: "libs", Paths: []string{"/usr/lib64"}}} {
+		if _, err := s.AddPackage(sub); err != nil {
+			t.Fatalf("AddPackage(%q) returned err: %v", sub.Suffix, err)
+		}
+	}
+	for _, f := range []RPMFile{
+		{Name: "/usr/lib64/libfoo.so.1", Body: []byte("foo")},
+		{Name: "/usr/bin/foo", HardLink: "/usr/lib64/libfoo.so.1"},
+	} {
+		if err := s.AddFile(f); err != nil {
+			t.Fatalf("AddFile(%q) returned err: %v", f.Name, err)
+		}
+	}
+	dir := t.TempDir()
+	if err := s.WriteDir(dir); !errors.Is(err, ErrCrossPackageHardLink) {
+		t.Errorf("WriteDir want ErrCrossPackageHardLink, got %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("WriteDir wrote %d packages before failing, want none", len(files))
+	}
+}
+
+func TestPackageSetMetadata(t *testing.T) {
+	s := NewPackageSet(RPMMetaData{Name: "foo", Version: "1.0", Compressor: "gzip", DigestAlgorithm: "sha256"})
+	base, err := s.AddPackage(Subpackage{})
+	if err != nil {
+		t.Fatalf("AddPackage returned err: %v", err)
+	}
+	doc, err := s.AddPackage(Subpackage{Suffix: "doc", Metadata: RPMMetaData{Compressor: "xz", DigestAlgorithm: "sha512", Reproducible: true}})
+	if err != nil {
+		t.Fatalf("AddPackage returned err: %v", err)
+	}
+	type settings struct {
+		Compressor, DigestAlgorithm string
+		Reproducible                bool
+	}
+	for _, tc := range []struct {
+		r    *RPM
+		want settings
+	}{
+		{base, settings{"gzip", "sha256", false}},
+		{doc, settings{"xz", "sha512", true}},
+	} {
+		got := settings{tc.r.Compressor, tc.r.DigestAlgorithm, tc.r.Reproducible}
+		if d := cmp.Diff(tc.want, got); d != "" {
+			t.Errorf("%s settings differ (want->got):\n%v", tc.r.Name, d)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"path"
+	"strings"
+)
+
+// ParentDirs configures the directories added for files without a parent
+// directory in the package.
+type ParentDirs struct {
+	// Roots are the directories under which missing parents are added,
+	// including the roots themselves, e.g. `/opt/ourco`.
+	Roots []string
+	// Mode, Owner and Group are the attributes of the added directories,
+	// like %defattr. They default to 0755, root and root.
+	Mode  uint
+	Owner string
+	Group string
+}
+
+// filesystemDirs are owned by the filesystem package, and are never added.
+var filesystemDirs = map[string]bool{
+	"/":                   true,
+	"/bin":                true,
+	"/boot":               true,
+	"/dev":                true,
+	"/etc":                true,
+	"/etc/opt":            true,
+	"/home":               true,
+	"/lib":                true,
+	"/lib64":              true,
+	"/media":              true,
+	"/mnt":                true,
+	"/opt":                true,
+	"/proc":               true,
+	"/root":               true,
+	"/run":                true,
+	"/sbin":               true,
+	"/srv":                true,
+	"/sys":                true,
+	"/tmp":                true,
+	"/usr":                true,
+	"/usr/bin":            true,
+	"/usr/include":        true,
+	"/usr/lib":            true,
+	"/usr/lib64":          true,
+	"/usr/libexec":        true,
+	"/usr/local":          true,
+	"/usr/local/bin":      true,
+	"/usr/local/etc":      true,
+	"/usr/local/include":  true,
+	"/usr/local/lib":      true,
+	"/usr/local/lib64":    true,
+	"/usr/local/libexec":  true,
+	"/usr/local/sbin":     true,
+	"/usr/local/share":    true,
+	"/usr/local/src":      true,
+	"/usr/sbin":           true,
+	"/usr/share":          true,
+	"/usr/share/doc":      true,
+	"/usr/share/info":     true,
+	"/usr/share/licenses": true,
+	"/usr/share/locale":   true,
+	"/usr/share/man":      true,
+	"/usr/src":            true,
+	"/var":                true,
+	"/var/cache":          true,
+	"/var/lib":            true,
+	"/var/log":            true,
+	"/var/opt":            true,
+	"/var/run":            true,
+	"/var/spool":          true,
+	"/var/tmp":            true,
+}
+
+// isFilesystemDir reports whether dir is owned by the filesystem package.
+// Besides the fixed list, it owns the man page sections and the message
+// catalog directories of every language.
+func isFilesystemDir(dir string) bool {
+	if filesystemDirs[dir] {
+		return true
+	}
+	if rel, ok := strings.CutPrefix(dir, "/usr/share/man/"); ok {
+		return !strings.Contains(rel, "/")
+	}
+	if rel, ok := strings.CutPrefix(dir, localeDir); ok {
+		lang, sub, nested := strings.Cut(rel, "/")
+		return lang != "" && (!nested || sub == "LC_MESSAGES")
+	}
+	return false
+}
+
+// underRoot reports whether dir is one of the roots or below one of them.
+func (p ParentDirs) underRoot(dir string) bool {
+	for _, root := range p.Roots {
+		root = path.Clean(root)
+		if dir == root || strings.HasPrefix(dir, strings.TrimSuffix(root, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetParentDirs makes Write add the missing parent directories of the files
+// under the configured roots. Directories owned by the filesystem package,
+// like /usr or /opt, are never added.
+func (r *RPM) SetParentDirs(p ParentDirs) {
+	if p.Mode == 0 {
+		p.Mode = 0755
+	}
+	if p.Owner == "" {
+		p.Owner = "root"
+	}
+	if p.Group == "" {
+		p.Group = "root"
+	}
+	r.parentDirs = &p
+}
+
+// addParentDirs adds the missing parent directories, as configured by
+// SetParentDirs. Source rpms have no directories.
+func (r *RPM) addParentDirs() {
+	if r.parentDirs == nil || r.IsSource {
+		return
+	}
+	var mtime uint32
+	if !r.BuildTime.IsZero() {
+		mtime = uint32(r.BuildTime.Unix())
+	}
+	var missing []string
+	for fn := range r.files {
+		for dir := path.Dir(fn); dir != "/"; dir = path.Dir(dir) {
+			if _, ok := r.files[dir]; ok {
+				continue
+			}
+			if r.parentDirs.underRoot(dir) && !isFilesystemDir(dir) {
+				missing = append(missing, dir)
+			}
+		}
+	}
+	for _, dir := range missing {
+		r.files[dir] = RPMFile{
+			Name:  dir,
+			Mode:  modeDir | r.parentDirs.Mode&^modeTypeMask,
+			Owner: r.parentDirs.Owner,
+			Group: r.parentDirs.Group,
+			MTime: mtime,
+		}
+	}
+}
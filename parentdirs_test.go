@@ -0,0 +1,123 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIsFilesystemDir(t *testing.T) {
+	for dir, want := range map[string]bool{
+		"/usr":                                   true,
+		"/usr/share/man/man1":                    true,
+		"/usr/share/man/de/man1":                 false,
+		"/usr/share/locale/de":                   true,
+		"/usr/share/locale/de/LC_MESSAGES":       true,
+		"/usr/share/locale/de/LC_TIME":           false,
+		"/opt":                                   true,
+		"/opt/ourco":                             false,
+		"/usr/lib/ourapp":                        false,
+		"/usr/share/locale/de/LC_MESSAGES/extra": false,
+	} {
+		if got := isFilesystemDir(dir); got != want {
+			t.Errorf("isFilesystemDir(%q) = %v, want %v", dir, got, want)
+		}
+	}
+}
+
+func TestParentDirs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		dirs      *ParentDirs
+		files     []RPMFile
+		wantNames []string
+		wantModes []uint16
+		wantOwner []string
+	}{{
+		name: "disabled",
+		files: []RPMFile{
+			{Name: "/opt/ourco/app/bin/app", Owner: "root", Group: "root"},
+		},
+		wantNames: []string{"app"},
+		wantModes: []uint16{0100000},
+		wantOwner: []string{"root"},
+	}, {
+		name: "missing parents under root",
+		dirs: &ParentDirs{Roots: []string{"/opt/ourco"}},
+		files: []RPMFile{
+			{Name: "/opt/ourco/app/bin/app", Mode: 0755, Owner: "root", Group: "root"},
+			{Name: "/opt/ourco/app/etc/app.conf", Mode: 0644, Owner: "root", Group: "root"},
+		},
+		wantNames: []string{"ourco", "app", "bin", "app", "etc", "app.conf"},
+		wantModes: []uint16{040755, 040755, 040755, 0100755, 040755, 0100644},
+		wantOwner: []string{"root", "root", "root", "root", "root", "root"},
+	}, {
+		name: "defattr and existing dirs",
+		dirs: &ParentDirs{Roots: []string{"/opt/ourco/"}, Mode: 0750, Owner: "ourco", Group: "ourco"},
+		files: []RPMFile{
+			{Name: "/opt/ourco/app", Mode: 040700, Owner: "app", Group: "app"},
+			{Name: "/opt/ourco/app/data/db", Mode: 0600, Owner: "app", Group: "app"},
+		},
+		wantNames: []string{"ourco", "app", "data", "db"},
+		wantModes: []uint16{040750, 040700, 040750, 0100600},
+		wantOwner: []string{"ourco", "app", "ourco", "app"},
+	}, {
+		name: "filesystem dirs stay unowned",
+		dirs: &ParentDirs{Roots: []string{"/usr"}},
+		files: []RPMFile{
+			{Name: "/usr/lib/ourapp/plugin.so", Mode: 0755, Owner: "root", Group: "root"},
+			{Name: "/usr/share/locale/de/LC_MESSAGES/ourapp.mo", Mode: 0644, Owner: "root", Group: "root"},
+		},
+		wantNames: []string{"ourapp", "plugin.so", "ourapp.mo"},
+		wantModes: []uint16{040755, 0100755, 0100644},
+		wantOwner: []string{"root", "root", "root"},
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRPM(RPMMetaData{BuildTime: time.Unix(1700000000, 0)})
+			if err != nil {
+				t.Fatalf("NewRPM returned error %v", err)
+			}
+			for _, f := range tc.files {
+				r.AddFile(f)
+			}
+			if tc.dirs != nil {
+				r.SetParentDirs(*tc.dirs)
+			}
+			if err := r.Write(io.Discard); err != nil {
+				t.Fatalf("Write returned error %v", err)
+			}
+			if d := cmp.Diff(tc.wantNames, r.basenames); d != "" {
+				t.Errorf("basenames differ (want->got):\n%v", d)
+			}
+			if d := cmp.Diff(tc.wantModes, r.filemodes); d != "" {
+				t.Errorf("file modes differ (want->got):\n%v", d)
+			}
+			if d := cmp.Diff(tc.wantOwner, r.fileowners); d != "" {
+				t.Errorf("file owners differ (want->got):\n%v", d)
+			}
+			for i, mode := range r.filemodes {
+				if mode&modeTypeMask == modeDir && r.fileowners[i] != "app" && r.filemtimes[i] != 1700000000 {
+					t.Errorf("added directory %s has mtime %d, want the build time", r.basenames[i], r.filemtimes[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	pkgConfigVarRE = regexp.MustCompile(`\$\$|\$\{([^}]*)\}`)
+	// pkgConfigTokenRE splits a Requires list into names, operators and
+	// versions, which may or may not be separated by spaces.
+	pkgConfigTokenRE = regexp.MustCompile(`[<>!]=|[<>=]|[^\s,<>!=]+`)
+)
+
+// PkgConfigDependencies is a DependencyGenerator for pkg-config files, like
+// rpm's pkgconfigdeps. Each */pkgconfig/*.pc file provides
+// `pkgconfig(name) = version` and requires the modules of its Requires and
+// Requires.private, as well as /usr/bin/pkg-config.
+type PkgConfigDependencies struct{}
+
+// Generate implements DependencyGenerator.
+func (PkgConfigDependencies) Generate(f RPMFile, content *io.SectionReader) (provides, requires Relations, err error) {
+	dir, file := path.Split(f.Name)
+	if path.Base(dir) != "pkgconfig" || !strings.HasSuffix(file, ".pc") {
+		return nil, nil, nil
+	}
+	vars := map[string]string{"pcfiledir": path.Clean(dir)}
+	expand := func(s string) string {
+		return pkgConfigVarRE.ReplaceAllStringFunc(s, func(m string) string {
+			if m == "$$" {
+				return "$"
+			}
+			return vars[m[2:len(m)-1]]
+		})
+	}
+	var version string
+	var reqs []string
+	s := bufio.NewScanner(content)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		i := strings.IndexAny(line, ":=")
+		if i <= 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if line[i] == '=' {
+			vars[key] = expand(value)
+			continue
+		}
+		switch key {
+		case "Version":
+			version = expand(value)
+		case "Requires", "Requires.private":
+			reqs = append(reqs, expand(value))
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+	if version == "" {
+		return nil, nil, fmt.Errorf("%s has no Version", f.Name)
+	}
+	provides = Relations{{Name: "pkgconfig(" + strings.TrimSuffix(file, ".pc") + ")", Version: version, Sense: SenseEqual}}
+
+	requires = Relations{{Name: "/usr/bin/pkg-config"}}
+	for _, req := range reqs {
+		rels, err := pkgConfigRequires(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid Requires %q in %s: %w", req, f.Name, err)
+		}
+		for _, rel := range rels {
+			requires.addIfMissing(rel)
+		}
+	}
+	return provides, requires, nil
+}
+
+// pkgConfigRequires parses a Requires list of pkg-config, e.g.
+// `glib-2.0 >= 2.50, gobject-2.0`. Modules which must not be some version
+// are required without a version.
+func pkgConfigRequires(list string) (Relations, error) {
+	var rels Relations
+	tokens := pkgConfigTokenRE.FindAllString(list, -1)
+	for i := 0; i < len(tokens); i++ {
+		if _, ok := stringToSense[tokens[i]]; ok || tokens[i] == "!=" {
+			return nil, fmt.Errorf("expected a module name, got %q", tokens[i])
+		}
+		rel := &Relation{Name: "pkgconfig(" + tokens[i] + ")"}
+		if i+1 < len(tokens) {
+			op := tokens[i+1]
+			sense, isSense := stringToSense[op]
+			if isSense || op == "!=" {
+				if i+2 >= len(tokens) {
+					return nil, fmt.Errorf("%s has no version after %q", tokens[i], op)
+				}
+				if op != "!=" {
+					rel.Sense, rel.Version = sense, tokens[i+2]
+				}
+				i += 2
+			}
+		}
+		rels.addIfMissing(rel)
+	}
+	return rels, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPkgConfigDependencies(t *testing.T) {
+	pc := `# A comment
+prefix=/usr
+libdir=${prefix}/lib64
+major=2
+minor=76
+
+Name: GIO
+Description: glib I/O library
+Version: ${major}.${minor}.1 # the release
+Requires: glib-2.0 >= ${major}.${minor}, gobject-2.0
+Requires.private: gmodule-no-export-2.0>=2.76 zlib!=1.2.12 mount
+Libs: -L${libdir} -lgio-2.0 -Wl,--enable-new-dtags=yes
+`
+	f := RPMFile{Name: "/usr/lib64/pkgconfig/gio-2.0.pc", Mode: 0644}
+	provides, requires, err := PkgConfigDependencies{}.Generate(f, io.NewSectionReader(strings.NewReader(pc), 0, int64(len(pc))))
+	if err != nil {
+		t.Fatalf("Generate returned err: %v", err)
+	}
+	if d := cmp.Diff(Relations{{Name: "pkgconfig(gio-2.0)", Version: "2.76.1", Sense: SenseEqual}}, provides); d != "" {
+		t.Errorf("provides differ (want->got):\n%v", d)
+	}
+	want := Relations{
+		{Name: "/usr/bin/pkg-config"},
+		{Name: "pkgconfig(glib-2.0)", Version: "2.76", Sense: SenseGreater | SenseEqual},
+		{Name: "pkgconfig(gobject-2.0)"},
+		{Name: "pkgconfig(gmodule-no-export-2.0)", Version: "2.76", Sense: SenseGreater | SenseEqual},
+		{Name: "pkgconfig(zlib)"},
+		{Name: "pkgconfig(mount)"},
+	}
+	if d := cmp.Diff(want, requires); d != "" {
+		t.Errorf("requires differ (want->got):\n%v", d)
+	}
+}
+
+func TestPkgConfigDependenciesOtherFiles(t *testing.T) {
+	for _, name := range []string{"/usr/share/foo/foo.pc", "/usr/lib64/pkgconfig/README"} {
+		provides, requires, err := PkgConfigDependencies{}.Generate(RPMFile{Name: name}, io.NewSectionReader(strings.NewReader(""), 0, 0))
+		if err != nil || provides != nil || requires != nil {
+			t.Errorf("Generate(%s) returned %v, %v, %v, want nothing", name, provides, requires, err)
+		}
+	}
+}
+
+func TestPkgConfigDependenciesErrors(t *testing.T) {
+	for _, pc := range []string{
+		"Name: foo\n",
+		"Version: 1\nRequires: bar >=\n",
+		"Version: 1\nRequires: >= 1\n",
+	} {
+		f := RPMFile{Name: "/usr/share/pkgconfig/foo.pc"}
+		if _, _, err := (PkgConfigDependencies{}).Generate(f, io.NewSectionReader(strings.NewReader(pc), 0, int64(len(pc)))); err == nil {
+			t.Errorf("Generate(%q) returned no error", pc)
+		}
+	}
+}
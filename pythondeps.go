@@ -0,0 +1,240 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	pep440RE = regexp.MustCompile(`^(?:(\d+)!)?(\d+(?:\.\d+)*)` +
+		`(?:[-_.]?(a|b|c|rc|alpha|beta|pre|preview)[-_.]?(\d*))?` +
+		`(?:-(\d+)|[-_.]?(post|rev|r)[-_.]?(\d*))?` +
+		`(?:[-_.]?(dev)[-_.]?(\d*))?$`)
+	pythonNameRE    = regexp.MustCompile(`[-_.]+`)
+	pythonRequireRE = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?\s*(.*)$`)
+	pythonAbiRE     = regexp.MustCompile(`/python(\d+\.\d+)/`)
+	pythonSpecRE    = regexp.MustCompile(`^(~=|===|==|!=|<=|>=|<|>)\s*(\S+)$`)
+)
+
+// pep440Pre are the normalized pre-release names of PEP 440.
+var pep440Pre = map[string]string{
+	"a":       "a",
+	"alpha":   "a",
+	"b":       "b",
+	"beta":    "b",
+	"c":       "rc",
+	"rc":      "rc",
+	"pre":     "rc",
+	"preview": "rc",
+}
+
+// PythonDistDependencies is a DependencyGenerator for Python packages, like
+// Fedora's pythondistdeps. The *.dist-info/METADATA and *.egg-info/PKG-INFO
+// files provide `python3dist(name) = version`, with the name normalized as
+// in PEP 503, and their Requires-Dist become requires on `python3dist(...)`.
+// Requirements with environment markers, like extras or python_version, are
+// left out since they depend on the installing system. Packages installed
+// under a versioned directory, like /usr/lib/python3.12/site-packages,
+// require `python(abi) = 3.12`.
+type PythonDistDependencies struct{}
+
+// Generate implements DependencyGenerator.
+func (PythonDistDependencies) Generate(f RPMFile, content *io.SectionReader) (provides, requires Relations, err error) {
+	dir, file := path.Split(f.Name)
+	dir = strings.TrimSuffix(dir, "/")
+	if !(file == "METADATA" && strings.HasSuffix(dir, ".dist-info") ||
+		file == "PKG-INFO" && strings.HasSuffix(dir, ".egg-info")) {
+		return nil, nil, nil
+	}
+	var name, version string
+	var reqs []string
+	s := bufio.NewScanner(content)
+	for s.Scan() {
+		line := s.Text()
+		// The headers end with an empty line, followed by the description.
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(key) {
+		case "name":
+			name = value
+		case "version":
+			version = value
+		case "requires-dist":
+			reqs = append(reqs, value)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+	if name == "" || version == "" {
+		return nil, nil, fmt.Errorf("%s has no Name or Version", f.Name)
+	}
+	provides = Relations{{Name: pythonDist(name), Version: pep440ToRPM(version), Sense: SenseEqual}}
+
+	if m := pythonAbiRE.FindStringSubmatch(f.Name); m != nil {
+		requires.addIfMissing(&Relation{Name: "python(abi)", Version: m[1], Sense: SenseEqual})
+	}
+	for _, req := range reqs {
+		rel, err := pythonRequirement(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid Requires-Dist %q in %s: %w", req, f.Name, err)
+		}
+		if rel != nil {
+			requires.addIfMissing(rel)
+		}
+	}
+	return provides, requires, nil
+}
+
+// pythonDist returns the python3dist name of a Python project, normalized as
+// in PEP 503, e.g. `python3dist(zope-interface)` for Zope.Interface.
+func pythonDist(name string) string {
+	return "python3dist(" + strings.ToLower(pythonNameRE.ReplaceAllString(name, "-")) + ")"
+}
+
+// pep440ToRPM converts a PEP 440 version to a version which sorts the same
+// in rpm: pre-releases and development releases sort before the release with
+// `~`, and post-releases after it with `^`, e.g. `1.0rc1` is `1.0~rc1`.
+// Versions which are not PEP 440 are kept as they are.
+func pep440ToRPM(v string) string {
+	v = strings.TrimPrefix(strings.ToLower(v), "v")
+	v, _, _ = strings.Cut(v, "+")
+	m := pep440RE.FindStringSubmatch(v)
+	if m == nil {
+		return v
+	}
+	num := func(s string) string {
+		if s == "" {
+			return "0"
+		}
+		n, _ := strconv.ParseUint(s, 10, 64)
+		return strconv.FormatUint(n, 10)
+	}
+	out := m[2]
+	if m[1] != "" {
+		out = num(m[1]) + ":" + out
+	}
+	if m[3] != "" {
+		out += "~" + pep440Pre[m[3]] + num(m[4])
+	}
+	switch {
+	case m[5] != "":
+		out += "^post" + num(m[5])
+	case m[6] != "":
+		out += "^post" + num(m[7])
+	}
+	if m[8] != "" {
+		out += "~~dev" + num(m[9])
+	}
+	return out
+}
+
+// pythonRequirement converts a Requires-Dist value, e.g.
+// `requests (>=2.0,<3); python_version >= "3.8"`, to a relation. Several
+// version specifiers are joined with `with` in a rich dependency.
+// Requirements with environment markers return nil.
+func pythonRequirement(req string) (*Relation, error) {
+	if _, marker, ok := strings.Cut(req, ";"); ok && strings.TrimSpace(marker) != "" {
+		return nil, nil
+	}
+	req, _, _ = strings.Cut(req, ";")
+	m := pythonRequireRE.FindStringSubmatch(strings.TrimSpace(req))
+	if m == nil {
+		return nil, fmt.Errorf("invalid project name")
+	}
+	name, specs := pythonDist(m[1]), strings.TrimSpace(m[3])
+	// Direct references, e.g. `foo @ https://...`, have no version.
+	if strings.HasPrefix(specs, "@") {
+		specs = ""
+	}
+	specs = strings.TrimSuffix(strings.TrimPrefix(specs, "("), ")")
+	var parts []string
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		sm := pythonSpecRE.FindStringSubmatch(spec)
+		if sm == nil {
+			return nil, fmt.Errorf("invalid version specifier %q", spec)
+		}
+		op, v := sm[1], sm[2]
+		switch {
+		case strings.HasSuffix(v, ".*") && (op == "==" || op == "!="):
+			// A prefix match, e.g. `== 1.2.*` is `>= 1.2 with < 1.3`.
+			lower := strings.TrimSuffix(v, ".*")
+			upper, err := pythonNextRelease(lower)
+			if err != nil {
+				return nil, err
+			}
+			lower, upper = pep440ToRPM(lower), pep440ToRPM(upper)
+			if op == "==" {
+				parts = append(parts, "("+name+" >= "+lower+" with "+name+" < "+upper+")")
+			} else {
+				parts = append(parts, "("+name+" < "+lower+" or "+name+" >= "+upper+")")
+			}
+		case op == "~=":
+			// A compatible release, e.g. `~= 1.4.2` is `>= 1.4.2 with < 1.5`.
+			vm := pep440RE.FindStringSubmatch(strings.ToLower(v))
+			if vm == nil || !strings.Contains(vm[2], ".") {
+				return nil, fmt.Errorf("%q needs a version with at least two release segments", spec)
+			}
+			release := strings.Split(vm[2], ".")
+			upper, err := pythonNextRelease(strings.Join(release[:len(release)-1], "."))
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, "("+name+" >= "+pep440ToRPM(v)+" with "+name+" < "+pep440ToRPM(upper)+")")
+		case op == "!=":
+			parts = append(parts, "("+name+" < "+pep440ToRPM(v)+" or "+name+" > "+pep440ToRPM(v)+")")
+		case op == "==" || op == "===":
+			parts = append(parts, name+" = "+pep440ToRPM(v))
+		default:
+			parts = append(parts, name+" "+op+" "+pep440ToRPM(v))
+		}
+	}
+	switch len(parts) {
+	case 0:
+		return &Relation{Name: name}, nil
+	case 1:
+		return NewRelation(parts[0])
+	}
+	return NewRelation("(" + strings.Join(parts, " with ") + ")")
+}
+
+// pythonNextRelease returns the release after a release prefix, e.g. `1.3`
+// for `1.2`.
+func pythonNextRelease(prefix string) (string, error) {
+	segments := strings.Split(prefix, ".")
+	last, err := strconv.ParseUint(segments[len(segments)-1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid release %q", prefix)
+	}
+	segments[len(segments)-1] = strconv.FormatUint(last+1, 10)
+	return strings.Join(segments, "."), nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPythonDistDependencies(t *testing.T) {
+	metadata := `Metadata-Version: 2.1
+Name: Zope.Interface
+Version: 6.0rc1
+Requires-Dist: setuptools
+Requires-Dist: requests[socks] (>=2.0,<3)
+Requires-Dist: pytest ; extra == "test"
+Requires-Dist: tomli>=1.1.0; python_version < "3.11"
+
+Requires-Dist: not a header
+`
+	f := RPMFile{Name: "/usr/lib/python3.12/site-packages/zope.interface-6.0rc1.dist-info/METADATA", Mode: 0644}
+	provides, requires, err := PythonDistDependencies{}.Generate(f, io.NewSectionReader(strings.NewReader(metadata), 0, int64(len(metadata))))
+	if err != nil {
+		t.Fatalf("Generate returned err: %v", err)
+	}
+	if d := cmp.Diff(Relations{{Name: "python3dist(zope-interface)", Version: "6.0~rc1", Sense: SenseEqual}}, provides); d != "" {
+		t.Errorf("provides differ (want->got):\n%v", d)
+	}
+	want := "python(abi)=3.12,python3dist(setuptools),(python3dist(requests) >= 2.0 with python3dist(requests) < 3)"
+	if got := requires.String(); got != want {
+		t.Errorf("requires want %q, got %q", want, got)
+	}
+}
+
+func TestPythonDistDependenciesOtherFiles(t *testing.T) {
+	for _, name := range []string{
+		"/usr/lib/python3.12/site-packages/foo/METADATA",
+		"/usr/lib/python3.12/site-packages/foo.dist-info/RECORD",
+		"/usr/lib/python3.12/site-packages/foo.egg-info/METADATA",
+	} {
+		provides, requires, err := PythonDistDependencies{}.Generate(RPMFile{Name: name}, io.NewSectionReader(strings.NewReader(""), 0, 0))
+		if err != nil || provides != nil || requires != nil {
+			t.Errorf("Generate(%s) returned %v, %v, %v, want nothing", name, provides, requires, err)
+		}
+	}
+	body := "Metadata-Version: 2.1\n"
+	f := RPMFile{Name: "/usr/lib/python3/dist-packages/foo.egg-info/PKG-INFO"}
+	if _, _, err := (PythonDistDependencies{}).Generate(f, io.NewSectionReader(strings.NewReader(body), 0, int64(len(body)))); err == nil {
+		t.Error("Generate returned no error for PKG-INFO without Name and Version")
+	}
+}
+
+func TestPEP440ToRPM(t *testing.T) {
+	testCases := []struct {
+		version, want string
+	}{
+		{"1.0", "1.0"},
+		{"v1.0", "1.0"},
+		{"1.0rc1", "1.0~rc1"},
+		{"1.0-alpha.2", "1.0~a2"},
+		{"1.0c1", "1.0~rc1"},
+		{"1.0b", "1.0~b0"},
+		{"1.0.post2", "1.0^post2"},
+		{"1.0-3", "1.0^post3"},
+		{"1.0r", "1.0^post0"},
+		{"1.0.dev4", "1.0~~dev4"},
+		{"1.0a1.dev01", "1.0~a1~~dev1"},
+		{"1.0.post1.dev2", "1.0^post1~~dev2"},
+		{"1!2.0", "1:2.0"},
+		{"1.0+local.7", "1.0"},
+		{"2023-rolling", "2023-rolling"},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.version, func(t *testing.T) {
+			if got := pep440ToRPM(tc.version); got != tc.want {
+				t.Errorf("pep440ToRPM(%q) want %q, got %q", tc.version, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPythonRequirement(t *testing.T) {
+	testCases := []struct {
+		req, want string
+	}{
+		{"Foo_Bar", "python3dist(foo-bar)"},
+		{"foo[extra1,extra2]", "python3dist(foo)"},
+		{"foo>=1.2", "python3dist(foo)>=1.2"},
+		{"foo (==1.2)", "python3dist(foo)=1.2"},
+		{"foo===1.2", "python3dist(foo)=1.2"},
+		{"foo<2.0b1", "python3dist(foo)<2.0~b1"},
+		{"foo ; ", "python3dist(foo)"},
+		{"foo ~= 1.4.2", "(python3dist(foo) >= 1.4.2 with python3dist(foo) < 1.5)"},
+		{"foo==1.2.*", "(python3dist(foo) >= 1.2 with python3dist(foo) < 1.3)"},
+		{"foo!=1.2.*", "(python3dist(foo) < 1.2 or python3dist(foo) >= 1.3)"},
+		{"foo!=1.5", "(python3dist(foo) < 1.5 or python3dist(foo) > 1.5)"},
+		{"foo>=1,!=1.5,<2", "(python3dist(foo) >= 1 with (python3dist(foo) < 1.5 or python3dist(foo) > 1.5) with python3dist(foo) < 2)"},
+		{"foo @ https://example.com/foo-1.0.tar.gz", "python3dist(foo)"},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.req, func(t *testing.T) {
+			rel, err := pythonRequirement(tc.req)
+			if err != nil {
+				t.Fatalf("pythonRequirement(%q) returned err: %v", tc.req, err)
+			}
+			if got := rel.String(); got != tc.want {
+				t.Errorf("pythonRequirement(%q) want %q, got %q", tc.req, tc.want, got)
+			}
+		})
+	}
+	if rel, err := pythonRequirement(`foo; sys_platform == "win32"`); rel != nil || err != nil {
+		t.Errorf("pythonRequirement with a marker returned %v, %v, want nothing", rel, err)
+	}
+	for _, req := range []string{"-foo", "foo ~= 1", "foo >> 1", "foo == 1.x.*"} {
+		if _, err := pythonRequirement(req); err == nil {
+			t.Errorf("pythonRequirement(%q) returned no error", req)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// reproducibleBuildHost is the build host of reproducible packages
+	// which don't set one, as used by the reproducible builds rpm macros.
+	reproducibleBuildHost = "reproducible"
+
+	// reproducibleGzipBlockSize is the block size of pgzip in reproducible
+	// mode. The output only depends on the block size, not on how many
+	// blocks are compressed in parallel, so it is pinned rather than left
+	// to the library default.
+	reproducibleGzipBlockSize = 1 << 20
+)
+
+// sourceDateEpoch reads the SOURCE_DATE_EPOCH environment variable.
+// https://reproducible-builds.org/specs/source-date-epoch/
+func sourceDateEpoch() (time.Time, bool, error) {
+	v, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok || v == "" {
+		return time.Time{}, false, nil
+	}
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || sec < 0 {
+		return time.Time{}, false, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q", v)
+	}
+	return time.Unix(sec, 0).UTC(), true, nil
+}
+
+// setupReproducible pins the metadata which would otherwise differ between
+// builds. SOURCE_DATE_EPOCH, when set, becomes the build time.
+func (r *RPM) setupReproducible() error {
+	if !r.Reproducible {
+		return nil
+	}
+	epoch, ok, err := sourceDateEpoch()
+	if err != nil {
+		return err
+	}
+	if ok {
+		r.BuildTime = epoch
+	}
+	if r.BuildHost == "" {
+		r.BuildHost = reproducibleBuildHost
+	}
+	return nil
+}
+
+// clampMTimes sets the modification time of files newer than the build time
+// to the build time, like rpmbuild's %clamp_mtime_to_source_date_epoch.
+func (r *RPM) clampMTimes() {
+	if !r.Reproducible || r.BuildTime.IsZero() {
+		return
+	}
+	max := uint32(r.BuildTime.Unix())
+	for fn, f := range r.files {
+		if f.MTime > max {
+			f.MTime = max
+			r.files[fn] = f
+		}
+	}
+}
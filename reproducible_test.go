@@ -0,0 +1,136 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// reproducibleInput is what must not change the output of a reproducible
+// build: the time of the build, the modification time of new files and the
+// order files are added in.
+type reproducibleInput struct {
+	buildTime, mtime time.Time
+	reverse          bool
+}
+
+func buildReproducible(t *testing.T, compressor string, body []byte, in reproducibleInput) []byte {
+	t.Helper()
+	r, err := NewRPM(RPMMetaData{
+		Name:         "reproducible",
+		Version:      "1.0",
+		Compressor:   compressor,
+		BuildTime:    in.buildTime,
+		Reproducible: true,
+		Requires:     Relations{{Name: "bash"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	files := []RPMFile{
+		{Name: "/opt/app", Mode: 040755, MTime: uint32(in.mtime.Unix())},
+		{Name: "/opt/app/data", Body: body, MTime: uint32(in.mtime.Unix())},
+		{Name: "/opt/app/old", Body: []byte("old"), MTime: 1000},
+		{Name: "/opt/app/z/new", Body: []byte("new"), MTime: uint32(in.mtime.Unix())},
+	}
+	for i := range files {
+		if in.reverse {
+			i = len(files) - 1 - i
+		}
+		r.AddFile(files[i])
+	}
+	r.AddPostin("echo installed")
+	b := &bytes.Buffer{}
+	if err := r.Write(b); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	return b.Bytes()
+}
+
+func TestReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	// A few pgzip blocks worth of data which is not too compressible.
+	body := make([]byte, 3*reproducibleGzipBlockSize+12345)
+	rnd := rand.New(rand.NewSource(1))
+	for i := range body {
+		body[i] = byte('a' + rnd.Intn(8))
+	}
+	for _, compressor := range []string{"gzip", "zstd", "xz"} {
+		compressor := compressor
+		t.Run(compressor, func(t *testing.T) {
+			// Files modified after SOURCE_DATE_EPOCH, at different times, and
+			// added in a different order.
+			first := buildReproducible(t, compressor, body, reproducibleInput{
+				buildTime: time.Now(),
+				mtime:     time.Now(),
+			})
+			second := buildReproducible(t, compressor, body, reproducibleInput{
+				buildTime: time.Now().Add(2 * time.Hour),
+				mtime:     time.Now().Add(time.Hour),
+				reverse:   true,
+			})
+			if !bytes.Equal(first, second) {
+				t.Errorf("two reproducible builds differ (%d and %d bytes)", len(first), len(second))
+			}
+		})
+	}
+}
+
+func TestReproducibleMetadata(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	r, err := NewRPM(RPMMetaData{Reproducible: true, BuildTime: time.Unix(1800000000, 0)})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	if got := r.BuildTime.Unix(); got != 1700000000 {
+		t.Errorf("BuildTime = %d, want SOURCE_DATE_EPOCH", got)
+	}
+	if r.BuildHost != reproducibleBuildHost {
+		t.Errorf("BuildHost = %q, want %q", r.BuildHost, reproducibleBuildHost)
+	}
+	r.AddFile(RPMFile{Name: "/new", MTime: 1800000000})
+	r.AddFile(RPMFile{Name: "/old", MTime: 1000})
+	r.clampMTimes()
+	if got := r.files["/new"].MTime; got != 1700000000 {
+		t.Errorf("/new MTime = %d, want it clamped to 1700000000", got)
+	}
+	if got := r.files["/old"].MTime; got != 1000 {
+		t.Errorf("/old MTime = %d, want 1000", got)
+	}
+
+	r, err = NewRPM(RPMMetaData{Reproducible: true, BuildHost: "builder"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	if r.BuildHost != "builder" {
+		t.Errorf("BuildHost = %q, want builder", r.BuildHost)
+	}
+
+	r, err = NewRPM(RPMMetaData{BuildTime: time.Unix(1800000000, 0)})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	if got := r.BuildTime.Unix(); got != 1800000000 || r.BuildHost != "" {
+		t.Errorf("SOURCE_DATE_EPOCH used without Reproducible: BuildTime %d BuildHost %q", got, r.BuildHost)
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, err := NewRPM(RPMMetaData{Reproducible: true}); err == nil {
+		t.Errorf("NewRPM accepted an invalid SOURCE_DATE_EPOCH")
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// RichOp is an operator of a rich (boolean) dependency.
+// https://rpm-software-management.github.io/rpm/manual/boolean_dependencies.html
+type RichOp int
+
+const (
+	// RichSimple is a leaf of the tree, a plain `name [op version]`.
+	RichSimple RichOp = iota
+	RichAnd
+	RichOr
+	RichIf
+	RichUnless
+	RichWith
+	RichWithout
+)
+
+var richOpNames = map[RichOp]string{
+	RichAnd:     "and",
+	RichOr:      "or",
+	RichIf:      "if",
+	RichUnless:  "unless",
+	RichWith:    "with",
+	RichWithout: "without",
+}
+
+// String returns the keyword of the operator.
+func (o RichOp) String() string {
+	return richOpNames[o]
+}
+
+// chains reports whether the operator takes more than two operands, like
+// `(a or b or c)`. Only and, or and with do.
+func (o RichOp) chains() bool {
+	return o == RichAnd || o == RichOr || o == RichWith
+}
+
+// RichDep is a parsed rich dependency. A simple dependency only holds the
+// Relation. Otherwise Op applies to the Operands, and Else is the optional
+// else branch of if and unless.
+type RichDep struct {
+	Op       RichOp
+	Relation *Relation
+	Operands []*RichDep
+	Else     *RichDep
+}
+
+// ParseRichDep parses a rich dependency, like `(foo >= 1.0 or (bar if baz))`.
+// Mixing operators without parentheses, chaining if, unless and without, and
+// unbalanced parentheses are errors, as they are for rpm.
+func ParseRichDep(s string) (*RichDep, error) {
+	tokens, err := richTokens(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &richParser{tokens: tokens}
+	if p.peek() != "(" {
+		return nil, fmt.Errorf("rich dependency %q must start with '('", s)
+	}
+	d, err := p.parseTerm()
+	if err != nil {
+		return nil, fmt.Errorf("invalid rich dependency %q: %w", s, err)
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("invalid rich dependency %q: unexpected %q after the closing ')'", s, p.peek())
+	}
+	return d, nil
+}
+
+// richTokens splits a rich dependency into parentheses, comparison operators
+// and words. Parentheses inside a word, like `perl(Foo::Bar)`, are part of it.
+func richTokens(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case strings.IndexByte("<>=", c) >= 0:
+			j := i
+			for j < len(s) && strings.IndexByte("<>=", s[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			j, depth := i, 0
+		word:
+			for ; j < len(s); j++ {
+				switch s[j] {
+				case '(':
+					depth++
+				case ')':
+					if depth == 0 {
+						break word
+					}
+					depth--
+				case '<', '>', '=', ' ', '\t', '\n', '\r':
+					if depth == 0 {
+						break word
+					}
+				}
+			}
+			if depth != 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in %q", s[i:])
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type richParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *richParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *richParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *richParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// richKeyword returns the operator of a keyword, and false for other words.
+func richKeyword(w string) (RichOp, bool) {
+	for op, name := range richOpNames {
+		if w == name {
+			return op, true
+		}
+	}
+	return RichSimple, w == "else"
+}
+
+// parseTerm parses a parenthesized expression or a simple dependency.
+func (p *richParser) parseTerm() (*RichDep, error) {
+	if p.peek() == "(" {
+		p.next()
+		d, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		return d, nil
+	}
+	name := p.next()
+	switch _, keyword := richKeyword(name); {
+	case name == "" || name == ")":
+		return nil, fmt.Errorf("missing dependency")
+	case keyword:
+		return nil, fmt.Errorf("missing dependency before %q", name)
+	case strings.IndexByte("<>=", name[0]) >= 0:
+		return nil, fmt.Errorf("missing name before %q", name)
+	}
+	rel := &Relation{Name: name}
+	if t := p.peek(); t != "" && strings.IndexByte("<>=", t[0]) >= 0 {
+		p.next()
+		sense, err := parseSense(t)
+		if err != nil {
+			return nil, err
+		}
+		version := p.next()
+		if _, keyword := richKeyword(version); version == "" || version == "(" || version == ")" || keyword {
+			return nil, fmt.Errorf("missing version after %q", name+" "+t)
+		}
+		rel.Sense, rel.Version = sense, version
+	}
+	return &RichDep{Relation: rel}, nil
+}
+
+// parseExpr parses the operands and operators up to the closing parenthesis.
+func (p *richParser) parseExpr() (*RichDep, error) {
+	first, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	d := &RichDep{Operands: []*RichDep{first}}
+	for p.peek() != ")" {
+		if p.done() {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		w := p.next()
+		op, keyword := richKeyword(w)
+		switch {
+		case !keyword:
+			return nil, fmt.Errorf("unknown operator %q", w)
+		case op == RichSimple: // else
+			if (d.Op != RichIf && d.Op != RichUnless) || d.Else != nil {
+				return nil, fmt.Errorf("else without if or unless")
+			}
+			if d.Else, err = p.parseTerm(); err != nil {
+				return nil, err
+			}
+			continue
+		case d.Op == RichSimple:
+			d.Op = op
+		case d.Op != op:
+			return nil, fmt.Errorf("cannot mix %q and %q without parentheses", d.Op, op)
+		case !op.chains():
+			return nil, fmt.Errorf("cannot chain %q", op)
+		}
+		operand, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		d.Operands = append(d.Operands, operand)
+	}
+	if d.Op == RichSimple {
+		return first, nil
+	}
+	return d, nil
+}
+
+// String returns the canonical form of the dependency: single spaces between
+// operands and operators, and parentheses around every operation.
+func (d *RichDep) String() string {
+	if d.Op == RichSimple {
+		if d.Relation.Version == "" {
+			return d.Relation.Name
+		}
+		return d.Relation.Name + " " + d.Relation.Sense.String() + " " + d.Relation.Version
+	}
+	operands := make([]string, len(d.Operands))
+	for i, o := range d.Operands {
+		operands[i] = o.String()
+	}
+	s := "(" + strings.Join(operands, " "+d.Op.String()+" ")
+	if d.Else != nil {
+		s += " else " + d.Else.String()
+	}
+	return s + ")"
+}
+
+// Eval evaluates the dependency, where satisfied reports whether a simple
+// dependency is satisfied. Evaluated over a flat set of capabilities, with
+// is the same as and, and without is `a and not b`: rpm additionally needs
+// both sides to be satisfied by the same package.
+func (d *RichDep) Eval(satisfied func(*Relation) bool) bool {
+	switch d.Op {
+	case RichSimple:
+		return satisfied(d.Relation)
+	case RichAnd, RichWith:
+		for _, o := range d.Operands {
+			if !o.Eval(satisfied) {
+				return false
+			}
+		}
+		return true
+	case RichOr:
+		for _, o := range d.Operands {
+			if o.Eval(satisfied) {
+				return true
+			}
+		}
+		return false
+	case RichIf:
+		if d.Operands[1].Eval(satisfied) {
+			return d.Operands[0].Eval(satisfied)
+		}
+		return d.Else == nil || d.Else.Eval(satisfied)
+	case RichUnless:
+		if !d.Operands[1].Eval(satisfied) {
+			return d.Operands[0].Eval(satisfied)
+		}
+		return d.Else == nil || d.Else.Eval(satisfied)
+	case RichWithout:
+		return d.Operands[0].Eval(satisfied) && !d.Operands[1].Eval(satisfied)
+	}
+	return false
+}
+
+// SatisfiedBy evaluates the dependency against a set of provides.
+func (d *RichDep) SatisfiedBy(provides Relations) bool {
+	return d.Eval(func(rel *Relation) bool {
+		for _, p := range provides {
+			if rel.overlaps(p) {
+				return true
+			}
+		}
+		return false
+	})
+}
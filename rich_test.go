@@ -0,0 +1,181 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRichDep(t *testing.T) {
+	testCases := []struct {
+		input, want string
+	}{
+		{"(foo or bar)", "(foo or bar)"},
+		{"(  foo   and\tbar and baz )", "(foo and bar and baz)"},
+		{"(foo>=1.0 or bar)", "(foo >= 1.0 or bar)"},
+		{"(perl(Foo::Bar) >= 1.2 with perl(Baz))", "(perl(Foo::Bar) >= 1.2 with perl(Baz))"},
+		{"(foo if bar else baz)", "(foo if bar else baz)"},
+		{"(foo unless (bar or baz) else (qux and quux))", "(foo unless (bar or baz) else (qux and quux))"},
+		{"(foo without bar)", "(foo without bar)"},
+		{"((foo or bar) and (baz if qux))", "((foo or bar) and (baz if qux))"},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.input, func(t *testing.T) {
+			d, err := ParseRichDep(tc.input)
+			if err != nil {
+				t.Fatalf("ParseRichDep(%q) returned err: %v", tc.input, err)
+			}
+			if got := d.String(); got != tc.want {
+				t.Errorf("ParseRichDep(%q).String() want %q, got %q", tc.input, tc.want, got)
+			}
+			again, err := ParseRichDep(d.String())
+			if err != nil {
+				t.Fatalf("ParseRichDep(%q) returned err: %v", d.String(), err)
+			}
+			if d := cmp.Diff(d, again); d != "" {
+				t.Errorf("canonical form parses differently (want->got):\n%v", d)
+			}
+		})
+	}
+}
+
+func TestParseRichDepTree(t *testing.T) {
+	d, err := ParseRichDep("(foo >= 1 or (bar if baz))")
+	if err != nil {
+		t.Fatalf("ParseRichDep returned err: %v", err)
+	}
+	want := &RichDep{
+		Op: RichOr,
+		Operands: []*RichDep{
+			{Relation: &Relation{Name: "foo", Version: "1", Sense: SenseGreater | SenseEqual}},
+			{Op: RichIf, Operands: []*RichDep{
+				{Relation: &Relation{Name: "bar"}},
+				{Relation: &Relation{Name: "baz"}},
+			}},
+		},
+	}
+	if d := cmp.Diff(want, d); d != "" {
+		t.Errorf("ParseRichDep tree differs (want->got):\n%v", d)
+	}
+}
+
+func TestParseRichDepErrors(t *testing.T) {
+	for _, input := range []string{
+		"foo or bar",
+		"(foo or bar",
+		"(foo or bar))",
+		"(a iff b)",
+		"(foo or bar and baz)",
+		"(foo if bar if baz)",
+		"(foo without bar without baz)",
+		"(foo else bar)",
+		"(foo if bar else baz else qux)",
+		"(or foo)",
+		"(foo or)",
+		"()",
+		"(foo >= )",
+		"(foo => 1)",
+		"(>= 1)",
+		"(foo(bar or baz)",
+	} {
+		if _, err := ParseRichDep(input); err == nil {
+			t.Errorf("ParseRichDep(%q) returned no error", input)
+		}
+		if _, err := NewRelation(input); err == nil && input[0] == '(' {
+			t.Errorf("NewRelation(%q) returned no error", input)
+		}
+	}
+}
+
+func TestRichDepEval(t *testing.T) {
+	provided := map[string]bool{"foo": true, "bar": true}
+	satisfied := func(rel *Relation) bool { return provided[rel.Name] }
+	testCases := []struct {
+		dep  string
+		want bool
+	}{
+		{"(foo and bar)", true},
+		{"(foo and qux)", false},
+		{"(qux or bar)", true},
+		{"(qux or quux)", false},
+		{"(foo = 1.2 with bar)", true},
+		{"(qux if bar)", false},
+		{"(qux if quux)", true},
+		{"(qux if quux else foo)", true},
+		{"(qux unless bar)", true},
+		{"(qux unless quux)", false},
+		{"(qux unless bar else foo)", true},
+		{"(foo without bar)", false},
+		{"(foo without qux)", true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.dep, func(t *testing.T) {
+			d, err := ParseRichDep(tc.dep)
+			if err != nil {
+				t.Fatalf("ParseRichDep(%q) returned err: %v", tc.dep, err)
+			}
+			if got := d.Eval(satisfied); got != tc.want {
+				t.Errorf("ParseRichDep(%q).Eval() want %v, got %v", tc.dep, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRichDepSatisfiedBy(t *testing.T) {
+	provides := Relations{
+		{Name: "foo", Version: "1.2-3", Sense: SenseEqual},
+		{Name: "bar"},
+		{Name: "baz", Version: "1:0.5", Sense: SenseEqual},
+	}
+	testCases := []struct {
+		dep  string
+		want bool
+	}{
+		{"(foo and bar)", true},
+		{"(foo and qux)", false},
+		{"(qux or bar)", true},
+		{"(qux or quux)", false},
+		{"(foo >= 1.2 and foo < 1.10)", true},
+		{"(foo > 1.2-3 or foo < 1.2)", false},
+		{"(foo = 1.2 with bar)", true},
+		{"(foo >= 1.2~rc1 and foo < 1.2^git1)", true},
+		{"(baz > 2.0)", true},
+		{"(baz < 1:0.5)", false},
+		{"(qux if bar)", false},
+		{"(qux if quux)", true},
+		{"(qux if quux else foo)", true},
+		{"(qux unless bar)", true},
+		{"(qux unless quux)", false},
+		{"(qux unless bar else foo)", true},
+		{"(foo without bar)", false},
+		{"(foo without qux)", true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.dep, func(t *testing.T) {
+			d, err := ParseRichDep(tc.dep)
+			if err != nil {
+				t.Fatalf("ParseRichDep(%q) returned err: %v", tc.dep, err)
+			}
+			if got := d.SatisfiedBy(provides); got != tc.want {
+				t.Errorf("ParseRichDep(%q).SatisfiedBy() want %v, got %v", tc.dep, tc.want, got)
+			}
+		})
+	}
+}
@@ -18,18 +18,19 @@
 package rpmpack
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math"
 	"path"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/cavaliergopher/cpio"
 	"github.com/klauspost/compress/zstd"
 	gzip "github.com/klauspost/pgzip"
 	"github.com/ulikunitz/xz"
@@ -41,6 +42,9 @@ var (
 	ErrWriteAfterClose = errors.New("rpm write after close")
 	// ErrWrongFileOrder is returned when files are not sorted by name.
 	ErrWrongFileOrder = errors.New("wrong file addition order")
+	// ErrSourceFilePath is returned when a file of a source rpm is in a
+	// directory.
+	ErrSourceFilePath = errors.New("source rpm files must be at flat paths")
 )
 
 // RPMMetaData contains meta info about the whole package.
@@ -59,31 +63,65 @@ type RPMMetaData struct {
 	Licence,
 	BuildHost,
 	Compressor,
+	// DigestAlgorithm is used for file and payload digests, one of md5,
+	// sha1, sha256 (the default), sha384 or sha512. Old distributions like
+	// EL6 only support md5 file digests.
+	DigestAlgorithm,
 	SourcePackage string
 	Epoch     uint32
 	BuildTime time.Time
 	// Prefixes is used for relocatable packages, usually with a one item
 	// slice, e.g. `["/opt"]`.
-	Prefixes  []string
+	Prefixes []string
 	Provides,
 	Obsoletes,
 	Suggests,
 	Recommends,
 	Requires,
 	Conflicts Relations
+	// OrderWithRequires only orders the installation, like Requires without
+	// requiring the package. Supplements and Enhances are reverse Recommends
+	// and Suggests.
+	OrderWithRequires,
+	Supplements,
+	Enhances Relations
 	Changelog
+	// Translations are the translated Summary, Description and Group,
+	// keyed by locale, e.g. `de` or `pt_BR`.
+	Translations map[string]Translation
+	// Reproducible makes the output only depend on the input: BuildTime is
+	// taken from SOURCE_DATE_EPOCH when it is set, file modification times
+	// are clamped to BuildTime, BuildHost defaults to "reproducible" and the
+	// compressors run deterministically.
+	Reproducible bool
+	// IsSource makes the package a source rpm (.src.rpm). Its files are at
+	// flat paths, like `foo.spec` or `foo-1.0.tar.gz`, with the spec file
+	// flagged as SpecFile. Sources and Patches are the file names of the
+	// sources and patches, as listed by the spec file.
+	IsSource bool
+	Sources,
+	Patches []string
 }
 
 // RPM holds the state of a particular rpm file. Please use NewRPM to instantiate it.
 type RPM struct {
 	RPMMetaData
 	di                *dirIndex
-	payload           *bytes.Buffer
-	payloadSize       uint
-	cpio              *cpio.Writer
+	payload           *spool
+	payloadDigest     hash.Hash
+	digest            digestAlgorithm
+	payloadSize       uint64
+	bodies            *spool
+	cpio              *cpioWriter
+	largeFiles        bool
 	basenames         []string
 	dirindexes        []uint32
-	filesizes         []uint32
+	filesizes         []uint64
+	fileinodes        []int32
+	filerdevs         []int16
+	fileverifyflags   []uint32
+	filecaps          []string
+	filelangs         []string
 	filemodes         []uint16
 	fileowners        []string
 	filegroups        []string
@@ -94,15 +132,21 @@ type RPM struct {
 	closed            bool
 	compressedPayload io.WriteCloser
 	files             map[string]RPMFile
-	prein             string
-	postin            string
-	preun             string
-	postun            string
-	pretrans          string
-	posttrans         string
+	parentDirs        *ParentDirs
+	prein             Scriptlet
+	postin            Scriptlet
+	preun             Scriptlet
+	postun            Scriptlet
+	pretrans          Scriptlet
+	posttrans         Scriptlet
+	verifyscript      Scriptlet
+	triggers          triggers
+	fileTriggers      fileTriggers
+	transFileTriggers fileTriggers
 	customTags        map[int]IndexEntry
 	customSigs        map[int]IndexEntry
 	pgpSigner         func([]byte) ([]byte, error)
+	generators        []DependencyGenerator
 	lead              *Lead
 	signatures        *index
 	headers           *index
@@ -120,26 +164,25 @@ func NewRPM(m RPMMetaData) (*RPM, error) {
 		m.Arch = "noarch"
 	}
 
-	p := &bytes.Buffer{}
+	rpm := &RPM{
+		RPMMetaData: m,
+		di:          newDirIndex(),
+		files:       make(map[string]RPMFile),
+		customTags:  make(map[int]IndexEntry),
+		customSigs:  make(map[int]IndexEntry),
+		lead:        NewLead(m),
+	}
 
-	z, compressorName, err := setupCompressor(m.Compressor, p)
-	if err != nil {
+	if err = rpm.setupReproducible(); err != nil {
 		return nil, err
 	}
 
-	// only use compressor name for the rpm tag, not the level
-	m.Compressor = compressorName
+	if err = rpm.resetPayload(); err != nil {
+		return nil, err
+	}
 
-	rpm := &RPM{
-		RPMMetaData:       m,
-		di:                newDirIndex(),
-		payload:           p,
-		compressedPayload: z,
-		cpio:              cpio.NewWriter(z),
-		files:             make(map[string]RPMFile),
-		customTags:        make(map[int]IndexEntry),
-		customSigs:        make(map[int]IndexEntry),
-		lead:              NewLead(m),
+	if rpm.IsSource {
+		return rpm, nil
 	}
 
 	// A package must provide itself...
@@ -154,7 +197,47 @@ func NewRPM(m RPMMetaData) (*RPM, error) {
 	return rpm, nil
 }
 
-func setupCompressor(compressorSetting string, w io.Writer) (wc io.WriteCloser,
+// resetPayload prepares an empty compressed cpio payload. The compressed
+// output goes to a spool, and its digest is computed while it is written.
+func (r *RPM) resetPayload() error {
+	digest, digestName, err := setupDigest(r.DigestAlgorithm)
+	if err != nil {
+		return err
+	}
+	r.digest = digest
+	r.DigestAlgorithm = digestName
+	r.payload = newSpool(spoolMemLimit)
+	r.payloadDigest = digest.new()
+
+	z, compressorName, err := setupCompressor(r.Compressor, io.MultiWriter(r.payload, r.payloadDigest), r.Reproducible)
+	if err != nil {
+		return err
+	}
+
+	// only use compressor name for the rpm tag, not the level
+	r.Compressor = compressorName
+	r.compressedPayload = z
+	r.cpio = newCpioWriter(z, false)
+	return nil
+}
+
+// Close removes the temporary files of the rpm: file contents read by FromTar
+// and the payload. Write calls it once the rpm is written, so it is only
+// needed for an rpm which is dropped without being written.
+func (r *RPM) Close() error {
+	var err error
+	for _, s := range []*spool{r.payload, r.bodies} {
+		if s == nil {
+			continue
+		}
+		if cerr := s.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func setupCompressor(compressorSetting string, w io.Writer, reproducible bool) (wc io.WriteCloser,
 	compressorType string, err error) {
 
 	parts := strings.Split(compressorSetting, ":")
@@ -184,7 +267,11 @@ func setupCompressor(compressorSetting string, w io.Writer) (wc io.WriteCloser,
 			}
 		}
 
-		wc, err = gzip.NewWriterLevel(w, level)
+		var gz *gzip.Writer
+		if gz, err = gzip.NewWriterLevel(w, level); err == nil && reproducible {
+			err = gz.SetConcurrency(reproducibleGzipBlockSize, runtime.GOMAXPROCS(0))
+		}
+		wc = gz
 	case "lzma":
 		if compressorLevel != "" {
 			return nil, "", fmt.Errorf("no compressor level supported for lzma: %s", compressorLevel)
@@ -213,7 +300,13 @@ func setupCompressor(compressorSetting string, w io.Writer) (wc io.WriteCloser,
 			}
 		}
 
-		wc, err = zstd.NewWriter(w, zstd.WithEncoderLevel(level))
+		opts := []zstd.EOption{zstd.WithEncoderLevel(level)}
+		if reproducible {
+			// Encode on one goroutine, so the output doesn't depend on
+			// the number of CPUs.
+			opts = append(opts, zstd.WithEncoderConcurrency(1))
+		}
+		wc, err = zstd.NewWriter(w, opts...)
 	default:
 		return nil, "", fmt.Errorf("unknown compressor type: %s", compressorType)
 	}
@@ -230,10 +323,20 @@ func (r *RPM) FullVersion() string {
 	return r.Version
 }
 
+// FileName returns the conventional file name of the package, like
+// `foo-1.0-1.x86_64.rpm` or `foo-1.0-1.src.rpm`.
+func (r *RPM) FileName() string {
+	arch := r.Arch
+	if r.IsSource {
+		arch = "src"
+	}
+	return fmt.Sprintf("%s-%s.%s.rpm", r.Name, r.FullVersion(), arch)
+}
+
 // AllowListDirs removes all directories which are not explicitly allowlisted.
 func (r *RPM) AllowListDirs(allowList map[string]bool) {
 	for fn, ff := range r.files {
-		if ff.Mode&040000 == 040000 {
+		if ff.Mode&modeTypeMask == modeDir {
 			if !allowList[fn] {
 				delete(r.files, fn)
 			}
@@ -246,14 +349,36 @@ func (r *RPM) Write(w io.Writer) error {
 	if r.closed {
 		return ErrWriteAfterClose
 	}
+	defer r.Close()
+	r.addParentDirs()
+	r.clampMTimes()
 	// Add all of the files, sorted alphabetically.
 	fnames := []string{}
 	for fn := range r.files {
+		if r.IsSource && strings.Contains(fn, "/") {
+			return fmt.Errorf("%w: %q", ErrSourceFilePath, fn)
+		}
 		fnames = append(fnames, fn)
+		if r.files[fn].size() > math.MaxUint32 {
+			r.largeFiles = true
+		}
 	}
 	sort.Strings(fnames)
+	if err := r.generateDependencies(); err != nil {
+		return err
+	}
+	r.addRPMLibRequires()
+	if r.largeFiles {
+		// The newc cpio format can't hold such files, rpm expects a stripped
+		// archive instead.
+		r.cpio.stripped = true
+	}
+	links, err := r.resolveHardLinks()
+	if err != nil {
+		return err
+	}
 	for _, fn := range fnames {
-		if err := r.writeFile(r.files[fn]); err != nil {
+		if err := r.writeFile(r.files[fn], links[fn]); err != nil {
 			return fmt.Errorf("failed to write file %q: %w", fn, err)
 		}
 	}
@@ -317,7 +442,7 @@ func (r *RPM) Write(w io.Writer) error {
 	}
 	r.headers = h
 
-	if _, err := w.Write(r.payload.Bytes()); err != nil {
+	if _, err := io.Copy(w, r.payload.Reader()); err != nil {
 		return fmt.Errorf("failed to write payload: %w", err)
 	}
 
@@ -328,15 +453,25 @@ func (r *RPM) Write(w io.Writer) error {
 // SetPGPSigner registers a function that will accept the header and payload as bytes,
 // and return a signature as bytes. The function should simulate what gpg does,
 // probably by using golang.org/x/crypto/openpgp or by forking a gpg process.
+// Note that the signature covers the payload, so the function is handed the
+// whole compressed payload in memory.
 func (r *RPM) SetPGPSigner(f func([]byte) ([]byte, error)) {
 	r.pgpSigner = f
 }
 
 // Only call this after the payload and header were written.
 func (r *RPM) writeSignatures(sigHeader *index, regHeader []byte) error {
-	sigHeader.Add(sigSize, EntryInt32([]int32{int32(r.payload.Len() + len(regHeader))}))
+	if size := uint64(r.payload.Len()) + uint64(len(regHeader)); size > math.MaxUint32 {
+		sigHeader.Add(sigLongSize, EntryUint64([]uint64{size}))
+	} else {
+		sigHeader.Add(sigSize, EntryUint32([]uint32{uint32(size)}))
+	}
 	sigHeader.Add(sigSHA256, EntryString(fmt.Sprintf("%x", sha256.Sum256(regHeader))))
-	sigHeader.Add(sigPayloadSize, EntryInt32([]int32{int32(r.payloadSize)}))
+	if r.payloadSize > math.MaxUint32 {
+		sigHeader.Add(sigLongArchiveSize, EntryUint64([]uint64{r.payloadSize}))
+	} else {
+		sigHeader.Add(sigPayloadSize, EntryUint32([]uint32{uint32(r.payloadSize)}))
+	}
 	if r.pgpSigner != nil {
 		// For sha 256 you need to sign the header and payload separately
 		header := append([]byte{}, regHeader...)
@@ -346,7 +481,11 @@ func (r *RPM) writeSignatures(sigHeader *index, regHeader []byte) error {
 		}
 		sigHeader.Add(sigRSA, EntryBytes(headerSig))
 
-		body := append(header, r.payload.Bytes()...)
+		payload, err := io.ReadAll(r.payload.Reader())
+		if err != nil {
+			return fmt.Errorf("failed to read payload: %w", err)
+		}
+		body := append(header, payload...)
 		bodySig, err := r.pgpSigner(body)
 		if err != nil {
 			return fmt.Errorf("call to signer failed: %w", err)
@@ -376,6 +515,15 @@ func (r *RPM) writeRelationIndexes(h *index) error {
 	if err := r.Conflicts.AddToIndex(h, tagConflicts, tagConflictVersion, tagConflictFlags); err != nil {
 		return fmt.Errorf("failed to add conflicts: %w", err)
 	}
+	if err := r.OrderWithRequires.AddToIndex(h, tagOrderName, tagOrderVersion, tagOrderFlags); err != nil {
+		return fmt.Errorf("failed to add order with requires: %w", err)
+	}
+	if err := r.Supplements.AddToIndex(h, tagSupplements, tagSupplementVersion, tagSupplementFlags); err != nil {
+		return fmt.Errorf("failed to add supplements: %w", err)
+	}
+	if err := r.Enhances.AddToIndex(h, tagEnhances, tagEnhanceVersion, tagEnhanceFlags); err != nil {
+		return fmt.Errorf("failed to add enhances: %w", err)
+	}
 
 	return nil
 }
@@ -405,15 +553,17 @@ func (r *RPM) GetSignatures() *index {
 const BIN_SH = "/bin/sh"
 
 func (r *RPM) writeGenIndexes(h *index) {
-	h.Add(tagHeaderI18NTable, EntryString("C"))
-	h.Add(tagSize, EntryInt32([]int32{int32(r.payloadSize)}))
+	r.addI18NToIndex(h)
+	if r.payloadSize > math.MaxUint32 {
+		h.Add(tagLongSize, EntryUint64([]uint64{r.payloadSize}))
+	} else {
+		h.Add(tagSize, EntryUint32([]uint32{uint32(r.payloadSize)}))
+	}
 	h.Add(tagName, EntryString(r.Name))
 	h.Add(tagVersion, EntryString(r.Version))
 	if r.Epoch > 0 {
 		h.Add(tagEpoch, EntryUint32([]uint32{r.Epoch}))
 	}
-	h.Add(tagSummary, EntryString(r.Summary))
-	h.Add(tagDescription, EntryString(r.Description))
 	h.Add(tagBuildHost, EntryString(r.BuildHost))
 	if !r.BuildTime.IsZero() {
 		// time.Time zero value is confusing, avoid if not supplied
@@ -432,51 +582,65 @@ func (r *RPM) writeGenIndexes(h *index) {
 	h.Add(tagVendor, EntryString(r.Vendor))
 	h.Add(tagLicence, EntryString(r.Licence))
 	h.Add(tagPackager, EntryString(r.Packager))
-	h.Add(tagGroup, EntryString(r.Group))
 	h.Add(tagURL, EntryString(r.URL))
-	h.Add(tagPayloadDigest, EntryStringSlice([]string{fmt.Sprintf("%x", sha256.Sum256(r.payload.Bytes()))}))
-	h.Add(tagPayloadDigestAlgo, EntryInt32([]int32{hashAlgoSHA256}))
+	h.Add(tagPayloadDigest, EntryStringSlice([]string{fmt.Sprintf("%x", r.payloadDigest.Sum(nil))}))
+	h.Add(tagPayloadDigestAlgo, EntryInt32([]int32{r.digest.id}))
 
 	// rpm utilities look for the sourcerpm tag to deduce if this is not a source rpm (if it has a sourcerpm,
 	// it is NOT a source rpm).
-	h.Add(tagSourceRPM, EntryString(r.SourcePackage))
-
-	if r.pretrans != "" {
-		h.Add(tagPretrans, EntryString(r.pretrans))
-		h.Add(tagPretransProg, EntryString(BIN_SH))
-	}
-	if r.prein != "" {
-		h.Add(tagPrein, EntryString(r.prein))
-		h.Add(tagPreinProg, EntryString(BIN_SH))
-	}
-	if r.postin != "" {
-		h.Add(tagPostin, EntryString(r.postin))
-		h.Add(tagPostinProg, EntryString(BIN_SH))
-	}
-	if r.preun != "" {
-		h.Add(tagPreun, EntryString(r.preun))
-		h.Add(tagPreunProg, EntryString(BIN_SH))
-	}
-	if r.postun != "" {
-		h.Add(tagPostun, EntryString(r.postun))
-		h.Add(tagPostunProg, EntryString(BIN_SH))
-	}
-	if r.posttrans != "" {
-		h.Add(tagPosttrans, EntryString(r.posttrans))
-		h.Add(tagPosttransProg, EntryString(BIN_SH))
+	if r.IsSource {
+		h.Add(tagSourcePackage, EntryInt32([]int32{1}))
+		if len(r.Sources) > 0 {
+			h.Add(tagSource, EntryStringSlice(r.Sources))
+		}
+		if len(r.Patches) > 0 {
+			h.Add(tagPatch, EntryStringSlice(r.Patches))
+		}
+	} else {
+		h.Add(tagSourceRPM, EntryString(r.SourcePackage))
 	}
 
+	r.pretrans.AddToIndex(h, tagPretrans, tagPretransProg)
+	r.prein.AddToIndex(h, tagPrein, tagPreinProg)
+	r.postin.AddToIndex(h, tagPostin, tagPostinProg)
+	r.preun.AddToIndex(h, tagPreun, tagPreunProg)
+	r.postun.AddToIndex(h, tagPostun, tagPostunProg)
+	r.posttrans.AddToIndex(h, tagPosttrans, tagPosttransProg)
+	r.verifyscript.AddToIndex(h, tagVerifyScript, tagVerifyScriptProg)
+	r.triggers.AddToIndex(h, triggerTagSet)
+	r.fileTriggers.AddToIndex(h, fileTriggerTagSet)
+	r.transFileTriggers.AddToIndex(h, transFileTriggerTagSet)
+
 	if r.RPMMetaData.Changelog != nil {
 		r.RPMMetaData.Changelog.AddToIndex(h)
 	}
 }
 
+// hasCaps reports whether any file has capabilities. rpm only expects the
+// capabilities tag in that case.
+func (r *RPM) hasCaps() bool {
+	for _, c := range r.filecaps {
+		if c != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // WriteFileIndexes writes file related index headers to the header
 func (r *RPM) writeFileIndexes(h *index) {
 	h.Add(tagBasenames, EntryStringSlice(r.basenames))
 	h.Add(tagDirindexes, EntryUint32(r.dirindexes))
 	h.Add(tagDirnames, EntryStringSlice(r.di.AllDirs()))
-	h.Add(tagFileSizes, EntryUint32(r.filesizes))
+	if r.largeFiles {
+		h.Add(tagLongFileSizes, EntryUint64(r.filesizes))
+	} else {
+		sizes := make([]uint32, len(r.filesizes))
+		for ii, size := range r.filesizes {
+			sizes[ii] = uint32(size)
+		}
+		h.Add(tagFileSizes, EntryUint32(sizes))
+	}
 	h.Add(tagFileModes, EntryUint16(r.filemodes))
 	h.Add(tagFileUserName, EntryStringSlice(r.fileowners))
 	h.Add(tagFileGroupName, EntryStringSlice(r.filegroups))
@@ -485,55 +649,81 @@ func (r *RPM) writeFileIndexes(h *index) {
 	h.Add(tagFileLinkTos, EntryStringSlice(r.filelinktos))
 	h.Add(tagFileFlags, EntryUint32(r.fileflags))
 
-	inodes := make([]int32, len(r.dirindexes))
+	h.Add(tagFileINodes, EntryInt32(r.fileinodes))
+
+	devices := make([]uint32, len(r.dirindexes))
 	digestAlgo := make([]int32, len(r.dirindexes))
-	verifyFlags := make([]int32, len(r.dirindexes))
-	fileRDevs := make([]int16, len(r.dirindexes))
-	fileLangs := make([]string, len(r.dirindexes))
-
-	for ii := range inodes {
-		// is inodes just a range from 1..len(dirindexes)? maybe different with hard links
-		inodes[ii] = int32(ii + 1)
-		digestAlgo[ii] = hashAlgoSHA256
-		// With regular files, it seems like we can always enable all of the verify flags
-		verifyFlags[ii] = int32(-1)
-		fileRDevs[ii] = int16(1)
-	}
-	h.Add(tagFileINodes, EntryInt32(inodes))
+
+	for ii := range devices {
+		// Hard links are detected by inode and device, all files live on
+		// one device.
+		devices[ii] = 1
+		digestAlgo[ii] = r.digest.id
+	}
+	h.Add(tagFileDevices, EntryUint32(devices))
 	h.Add(tagFileDigestAlgo, EntryInt32(digestAlgo))
-	h.Add(tagFileVerifyFlags, EntryInt32(verifyFlags))
-	h.Add(tagFileRDevs, EntryInt16(fileRDevs))
-	h.Add(tagFileLangs, EntryStringSlice(fileLangs))
+	h.Add(tagFileVerifyFlags, EntryUint32(r.fileverifyflags))
+	h.Add(tagFileRDevs, EntryInt16(r.filerdevs))
+	h.Add(tagFileLangs, EntryStringSlice(r.filelangs))
+	if r.hasCaps() {
+		h.Add(tagFileCaps, EntryStringSlice(r.filecaps))
+	}
+}
+
+// AddPretrans adds a pretrans scriptlet. The optional interpreter is the
+// program and arguments running it, /bin/sh by default.
+func (r *RPM) AddPretrans(s string, interpreter ...string) {
+	r.pretrans = NewScriptlet(s, interpreter...)
+}
+
+// AddPrein adds a prein scriptlet. The optional interpreter is the program and
+// arguments running it, /bin/sh by default.
+func (r *RPM) AddPrein(s string, interpreter ...string) {
+	r.prein = NewScriptlet(s, interpreter...)
 }
 
-// AddPretrans adds a pretrans scriptlet
-func (r *RPM) AddPretrans(s string) {
-	r.pretrans = s
+// AddPostin adds a postin scriptlet. The optional interpreter is the program
+// and arguments running it, /bin/sh by default.
+func (r *RPM) AddPostin(s string, interpreter ...string) {
+	r.postin = NewScriptlet(s, interpreter...)
 }
 
-// AddPrein adds a prein scriptlet
-func (r *RPM) AddPrein(s string) {
-	r.prein = s
+// AddPreun adds a preun scriptlet. The optional interpreter is the program and
+// arguments running it, /bin/sh by default.
+func (r *RPM) AddPreun(s string, interpreter ...string) {
+	r.preun = NewScriptlet(s, interpreter...)
 }
 
-// AddPostin adds a postin scriptlet
-func (r *RPM) AddPostin(s string) {
-	r.postin = s
+// AddPostun adds a postun scriptlet. The optional interpreter is the program
+// and arguments running it, /bin/sh by default.
+func (r *RPM) AddPostun(s string, interpreter ...string) {
+	r.postun = NewScriptlet(s, interpreter...)
 }
 
-// AddPreun adds a preun scriptlet
-func (r *RPM) AddPreun(s string) {
-	r.preun = s
+// AddPosttrans adds a posttrans scriptlet. The optional interpreter is the
+// program and arguments running it, /bin/sh by default.
+func (r *RPM) AddPosttrans(s string, interpreter ...string) {
+	r.posttrans = NewScriptlet(s, interpreter...)
 }
 
-// AddPostun adds a postun scriptlet
-func (r *RPM) AddPostun(s string) {
-	r.postun = s
+// AddVerifyScript adds a %verifyscript, run by `rpm -V`. The optional
+// interpreter is the program and arguments running the script.
+func (r *RPM) AddVerifyScript(s string, interpreter ...string) {
+	r.verifyscript = NewScriptlet(s, interpreter...)
 }
 
-// AddPosttrans adds a posttrans scriptlet
-func (r *RPM) AddPosttrans(s string) {
-	r.posttrans = s
+// scriptlets returns all the install scriptlets of the package, and the
+// verify script.
+func (r *RPM) scriptlets() []Scriptlet {
+	return []Scriptlet{r.pretrans, r.prein, r.postin, r.preun, r.postun, r.posttrans, r.verifyscript}
+}
+
+// allScriptlets returns the install scriptlets and the scripts of all the
+// triggers of the package.
+func (r *RPM) allScriptlets() []Scriptlet {
+	s := append(r.scriptlets(), r.triggers.scriptlets()...)
+	s = append(s, r.fileTriggers.scriptlets()...)
+	return append(s, r.transFileTriggers.scriptlets()...)
 }
 
 // AddFile adds an RPMFile to an existing rpm.
@@ -541,6 +731,9 @@ func (r *RPM) AddFile(f RPMFile) {
 	if f.Name == "/" { // rpm does not allow the root dir to be included.
 		return
 	}
+	if r.IsSource {
+		f.Name = strings.TrimPrefix(f.Name, "/")
+	}
 	r.files[f.Name] = f
 }
 
@@ -548,8 +741,16 @@ func (r *RPM) SetChangelog(c Changelog) {
 	r.RPMMetaData.Changelog = c
 }
 
-// writeFile writes the file to the indexes and cpio.
-func (r *RPM) writeFile(f RPMFile) error {
+// writeFile writes the file to the indexes and cpio. g is the group of hard
+// links the file belongs to, if any.
+func (r *RPM) writeFile(f RPMFile, g *linkGroup) error {
+	if g != nil {
+		f = g.apply(f)
+	}
+	rdev, err := f.rdev()
+	if err != nil {
+		return err
+	}
 	dir, file := path.Split(f.Name)
 	r.dirindexes = append(r.dirindexes, r.di.Get(dir))
 	r.basenames = append(r.basenames, file)
@@ -557,47 +758,121 @@ func (r *RPM) writeFile(f RPMFile) error {
 	r.filegroups = append(r.filegroups, f.Group)
 	r.filemtimes = append(r.filemtimes, f.MTime)
 	r.fileflags = append(r.fileflags, uint32(f.Type))
+	r.fileverifyflags = append(r.fileverifyflags, uint32(VerifyAll&^f.NoVerify))
+	r.filecaps = append(r.filecaps, f.Capabilities)
+	r.filelangs = append(r.filelangs, f.lang())
 
+	inode := int32(len(r.basenames))
 	links := 1
-	switch {
-	case f.Mode&040000 != 0: // directory
+	if g != nil {
+		if g.inode == 0 {
+			g.inode = inode
+		}
+		inode = g.inode
+		links = len(g.names)
+	}
+	r.fileinodes = append(r.fileinodes, inode)
+	r.filerdevs = append(r.filerdevs, rdev)
+
+	regular := false
+	switch f.Mode & modeTypeMask {
+	case modeDir:
 		r.filesizes = append(r.filesizes, 4096)
-		r.filedigests = append(r.filedigests, "")
 		r.filelinktos = append(r.filelinktos, "")
 		links = 2
-	case f.Mode&0120000 == 0120000: //  symlink
-		r.filesizes = append(r.filesizes, uint32(len(f.Body)))
-		r.filedigests = append(r.filedigests, "")
+	case modeSymlink:
+		r.filesizes = append(r.filesizes, uint64(len(f.Body)))
 		r.filelinktos = append(r.filelinktos, string(f.Body))
+	case modeChar, modeBlock, modeFIFO, modeSocket:
+		// Special files have no content.
+		f.Body, f.Source, f.Size = nil, nil, 0
+		r.filesizes = append(r.filesizes, 0)
+		r.filelinktos = append(r.filelinktos, "")
 	default: // regular file
 		f.Mode = f.Mode | 0100000
-		r.filesizes = append(r.filesizes, uint32(len(f.Body)))
-		r.filedigests = append(r.filedigests, fmt.Sprintf("%x", sha256.Sum256(f.Body)))
+		r.filesizes = append(r.filesizes, uint64(f.size()))
 		r.filelinktos = append(r.filelinktos, "")
+		regular = true
 	}
 	r.filemodes = append(r.filemodes, uint16(f.Mode))
 
-	// Ghost files have no payload
-	if f.Type == GhostFile {
-		return nil
+	var digest string
+	switch {
+	case f.Type == GhostFile:
+		// Ghost files have no payload
+		if regular {
+			digest, err = copyBody(io.Discard, f, r.digest.new())
+		}
+	case g != nil && !g.last(f.Name):
+		// The content of hard links is only written with the last link,
+		// so the digest is only known then.
+		g.pending = append(g.pending, len(r.filedigests))
+		f.Body, f.Source, f.Size = nil, nil, 0
+		_, err = r.writePayload(f, inode, links)
+	default:
+		digest, err = r.writePayload(f, inode, links)
+		if g != nil {
+			for _, ii := range g.pending {
+				r.filedigests[ii] = digest
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !regular {
+		digest = ""
+	}
+	r.filedigests = append(r.filedigests, digest)
+	return nil
+}
+
+// writePayload writes the file to the cpio payload, and returns the digest of
+// its content.
+func (r *RPM) writePayload(f RPMFile, inode int32, links int) (string, error) {
+	name := "." + f.Name
+	if r.IsSource {
+		// Source rpms have flat paths, without the prefix.
+		name = f.Name
+	}
+	e := cpioEntry{
+		name: name,
+		// The file index is the position of the file in the header.
+		fx:        len(r.basenames) - 1,
+		inode:     inode,
+		mode:      f.Mode,
+		links:     links,
+		mtime:     f.MTime,
+		size:      f.size(),
+		rdevMajor: f.DevMajor,
+		rdevMinor: f.DevMinor,
+	}
+	if err := r.cpio.WriteHeader(e); err != nil {
+		return "", fmt.Errorf("failed to write payload file header: %w", err)
+	}
+	digest, err := copyBody(r.cpio, f, r.digest.new())
+	if err != nil {
+		return "", err
 	}
-	return r.writePayload(f, links)
+	r.payloadSize += uint64(e.size)
+	return digest, nil
 }
 
-func (r *RPM) writePayload(f RPMFile, links int) error {
-	hdr := &cpio.Header{
-		Name:  f.Name,
-		Mode:  cpio.FileMode(f.Mode),
-		Size:  int64(len(f.Body)),
-		Links: links,
-		ModTime: time.Unix(int64(f.MTime), 0),
+// copyBody streams the content of the file to w, and returns the hex encoded
+// digest of the content. Size and digest are computed in the same pass.
+func copyBody(w io.Writer, f RPMFile, h hash.Hash) (string, error) {
+	rc, err := f.open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open file content: %w", err)
 	}
-	if err := r.cpio.WriteHeader(hdr); err != nil {
-		return fmt.Errorf("failed to write payload file header: %w", err)
+	defer rc.Close()
+
+	n, err := io.Copy(io.MultiWriter(w, h), rc)
+	if err != nil {
+		return "", fmt.Errorf("failed to write payload file content: %w", err)
 	}
-	if _, err := r.cpio.Write(f.Body); err != nil {
-		return fmt.Errorf("failed to write payload file content: %w", err)
+	if n != f.size() {
+		return "", fmt.Errorf("file content is %d bytes, expected %d", n, f.size())
 	}
-	r.payloadSize += uint(len(f.Body))
-	return nil
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
@@ -67,8 +67,7 @@ func readHeaders(file *os.File, out *RPM) error {
 
 func readGenIndexes(out *RPM) {
 	out.Name, _ = popTag(out.headers.entries, tagName, IndexEntry.toString)
-	out.Summary, _ = popTag(out.headers.entries, tagSummary, IndexEntry.toString)
-	out.Description, _ = popTag(out.headers.entries, tagDescription, IndexEntry.toString)
+	readI18N(out)
 	out.Version, _ = popTag(out.headers.entries, tagVersion, IndexEntry.toString)
 	out.Release, _ = popTag(out.headers.entries, tagRelease, IndexEntry.toString)
 	out.Arch, _ = popTag(out.headers.entries, tagArch, IndexEntry.toString)
@@ -76,7 +75,6 @@ func readGenIndexes(out *RPM) {
 	out.Vendor, _ = popTag(out.headers.entries, tagVendor, IndexEntry.toString)
 	out.URL, _ = popTag(out.headers.entries, tagURL, IndexEntry.toString)
 	out.Packager, _ = popTag(out.headers.entries, tagPackager, IndexEntry.toString)
-	out.Group, _ = popTag(out.headers.entries, tagGroup, IndexEntry.toString)
 	out.Licence, _ = popTag(out.headers.entries, tagLicence, IndexEntry.toString)
 	out.BuildHost, _ = popTag(out.headers.entries, tagBuildHost, IndexEntry.toString)
 	out.Compressor, _ = popTag(out.headers.entries, tagPayloadCompressor, IndexEntry.toString)
@@ -84,6 +82,11 @@ func readGenIndexes(out *RPM) {
 	out.BuildTime, _ = popTag(out.headers.entries, tagBuildTime, IndexEntry.toTime)
 	out.Prefixes, _ = popTag(out.headers.entries, tagPrefixes, IndexEntry.toStringArray)
 	out.SourcePackage, _ = popTag(out.headers.entries, tagSourceRPM, IndexEntry.toString)
+	if _, err := popTag(out.headers.entries, tagSourcePackage, IndexEntry.toInt32Array); err == nil {
+		out.IsSource = true
+	}
+	out.Sources, _ = popTag(out.headers.entries, tagSource, IndexEntry.toStringArray)
+	out.Patches, _ = popTag(out.headers.entries, tagPatch, IndexEntry.toStringArray)
 
 	out.Provides, _ = out.headers.toRelations(tagProvides, tagProvideVersion, tagProvideFlags)
 	out.Obsoletes, _ = out.headers.toRelations(tagObsoletes, tagObsoleteVersion, tagObsoleteFlags)
@@ -91,6 +94,9 @@ func readGenIndexes(out *RPM) {
 	out.Recommends, _ = out.headers.toRelations(tagRecommends, tagRecommendVersion, tagRecommendFlags)
 	out.Requires, _ = out.headers.toRelations(tagRequires, tagRequireVersion, tagRequireFlags)
 	out.Conflicts, _ = out.headers.toRelations(tagConflicts, tagConflictVersion, tagConflictFlags)
+	out.OrderWithRequires, _ = out.headers.toRelations(tagOrderName, tagOrderVersion, tagOrderFlags)
+	out.Supplements, _ = out.headers.toRelations(tagSupplements, tagSupplementVersion, tagSupplementFlags)
+	out.Enhances, _ = out.headers.toRelations(tagEnhances, tagEnhanceVersion, tagEnhanceFlags)
 	out.Changelog, _ = out.headers.toChangelog()
 }
 
@@ -100,7 +106,15 @@ func readFileIndexes(out *RPM) {
 	out.di = newDirIndex()
 	out.di.l, _ = popTag(out.headers.entries, tagDirnames, IndexEntry.toStringArray)
 
-	out.filesizes, _ = popTag(out.headers.entries, tagFileSizes, IndexEntry.toUint32Array)
+	if sizes, err := popTag(out.headers.entries, tagLongFileSizes, IndexEntry.toUint64Array); err == nil {
+		out.filesizes = sizes
+		out.largeFiles = true
+	} else {
+		sizes, _ := popTag(out.headers.entries, tagFileSizes, IndexEntry.toUint32Array)
+		for _, size := range sizes {
+			out.filesizes = append(out.filesizes, uint64(size))
+		}
+	}
 	out.filemodes, _ = popTag(out.headers.entries, tagFileModes, IndexEntry.toUint16Array)
 	out.fileowners, _ = popTag(out.headers.entries, tagFileUserName, IndexEntry.toStringArray)
 	out.filegroups, _ = popTag(out.headers.entries, tagFileGroupName, IndexEntry.toStringArray)
@@ -109,21 +123,34 @@ func readFileIndexes(out *RPM) {
 	out.filelinktos, _ = popTag(out.headers.entries, tagFileLinkTos, IndexEntry.toStringArray)
 	out.fileflags, _ = popTag(out.headers.entries, tagFileFlags, IndexEntry.toUint32Array)
 
-	popTag(out.headers.entries, tagFileINodes, IndexEntry.toInt32Array)
-	popTag(out.headers.entries, tagFileDigestAlgo, IndexEntry.toInt32Array)
-	popTag(out.headers.entries, tagFileVerifyFlags, IndexEntry.toInt32Array)
-	popTag(out.headers.entries, tagFileRDevs, IndexEntry.toInt16Array)
-	popTag(out.headers.entries, tagFileLangs, IndexEntry.toStringArray)
+	out.fileinodes, _ = popTag(out.headers.entries, tagFileINodes, IndexEntry.toInt32Array)
+	popTag(out.headers.entries, tagFileDevices, IndexEntry.toUint32Array)
+	if algos, err := popTag(out.headers.entries, tagFileDigestAlgo, IndexEntry.toInt32Array); err == nil && len(algos) > 0 {
+		out.DigestAlgorithm, _ = digestNameFromID(algos[0])
+	} else if len(out.basenames) > 0 {
+		// rpm uses md5 when the algorithm is not specified.
+		out.DigestAlgorithm = "md5"
+	}
+	out.fileverifyflags, _ = popTag(out.headers.entries, tagFileVerifyFlags, IndexEntry.toUint32Array)
+	out.filerdevs, _ = popTag(out.headers.entries, tagFileRDevs, IndexEntry.toInt16Array)
+	out.filecaps, _ = popTag(out.headers.entries, tagFileCaps, IndexEntry.toStringArray)
+	out.filelangs, _ = popTag(out.headers.entries, tagFileLangs, IndexEntry.toStringArray)
 }
 
-func readScript(data *RPM, tagScript int, tagProgram int, name string) (string, error){
-	script, _ := popTag(data.headers.entries, tagPretrans, IndexEntry.toString)
-	pretransProg, _ := popTag(data.headers.entries, tagPretransProg, IndexEntry.toString)
+func readScript(data *RPM, tagScript int, tagProgram int, name string) (Scriptlet, error) {
+	script, _ := popTag(data.headers.entries, tagScript, IndexEntry.toString)
 
-	if script != "" && pretransProg != "/bin/sh" {
-		return "", fmt.Errorf("%s script %s does not match expected script %s", name, pretransProg, "/bin/sh")
+	// Without an interpreter tag, rpm runs the script with /bin/sh.
+	var prog []string
+	if _, ok := data.headers.entries[tagProgram]; ok {
+		var err error
+		prog, err = popTag(data.headers.entries, tagProgram, interpreterFromEntry)
+		if err != nil {
+			return Scriptlet{}, fmt.Errorf("failed to read %s interpreter: %w", name, err)
+		}
 	}
-	return script, nil
+
+	return Scriptlet{Body: script, Interpreter: prog}, nil
 }
 
 func readScripts(out *RPM) error {
@@ -158,6 +185,26 @@ func readScripts(out *RPM) error {
 		return err
 	}
 
+	out.verifyscript, err = readScript(out, tagVerifyScript, tagVerifyScriptProg, "verifyscript")
+	if err != nil {
+		return err
+	}
+
+	out.triggers, err = readTriggers(out.headers, triggerTagSet)
+	if err != nil {
+		return fmt.Errorf("failed to read triggers: %w", err)
+	}
+
+	out.fileTriggers, err = readFileTriggers(out.headers, fileTriggerTagSet)
+	if err != nil {
+		return fmt.Errorf("failed to read file triggers: %w", err)
+	}
+
+	out.transFileTriggers, err = readFileTriggers(out.headers, transFileTriggerTagSet)
+	if err != nil {
+		return fmt.Errorf("failed to read transaction file triggers: %w", err)
+	}
+
 	return nil
 }
 
@@ -254,14 +301,32 @@ func readFile(r *cpio.Reader, i int, out *RPM) (error) {
 	if err != nil {
 		return err
 	}
+	// Payload file names of binary packages are prefixed with ".", like
+	// ./usr/bin/tool. Source packages have flat names, like foo.spec.
+	name := h.Name
+	if strings.HasPrefix(name, "./") {
+		name = name[1:]
+	}
 	ret := RPMFile{
-		Name: h.Name,
+		Name: name,
 		Mode: uint(out.filemodes[i]),
 		Owner: out.fileowners[i],
 		Group: out.filegroups[i],
 		MTime: uint32(h.ModTime.Unix()),
 		Type: FileType(out.fileflags[i]),
 	}
+	if i < len(out.filerdevs) {
+		ret.setRDev(out.filerdevs[i])
+	}
+	if i < len(out.fileverifyflags) {
+		ret.NoVerify = VerifyAll &^ VerifyFlags(out.fileverifyflags[i])
+	}
+	if i < len(out.filecaps) {
+		ret.Capabilities = out.filecaps[i]
+	}
+	if i < len(out.filelangs) {
+		ret.Lang = out.filelangs[i]
+	}
 
 	buff := make([]byte, h.Size)
 	count, err := r.Read(buff)
@@ -274,11 +339,90 @@ func readFile(r *cpio.Reader, i int, out *RPM) (error) {
 		ret.Body = []byte(h.Linkname)
 	}
 
-	out.files[h.Name] = ret
+	out.files[name] = ret
 
 	return nil
 }
 
+// readStrippedFiles reads the files of a stripped cpio payload, which relies on
+// the file indexes of the header for all metadata.
+func readStrippedFiles(data []byte, out *RPM) error {
+	// Only the last member of a hard link set carries the content, the
+	// others are empty, like the sizes of the newc headers say.
+	content := map[int32]int{}
+	for fx, inode := range out.fileinodes {
+		if fx < len(out.filemodes) && out.filemodes[fx]&modeTypeMask == modeRegular && FileType(out.fileflags[fx])&GhostFile == 0 {
+			content[inode] = fx
+		}
+	}
+	sizeOf := func(fx int) (int64, error) {
+		if fx >= len(out.basenames) || fx >= len(out.filesizes) {
+			return 0, fmt.Errorf("stripped cpio file index %d out of range", fx)
+		}
+		switch out.filemodes[fx] & modeTypeMask {
+		case modeRegular:
+			if fx < len(out.fileinodes) && content[out.fileinodes[fx]] != fx {
+				return 0, nil
+			}
+			return int64(out.filesizes[fx]), nil
+		case modeSymlink:
+			return int64(out.filesizes[fx]), nil
+		}
+		return 0, nil
+	}
+	return readStripped(data, sizeOf, func(fx int, body []byte) error {
+		name := out.di.l[out.dirindexes[fx]] + out.basenames[fx]
+		f := RPMFile{
+			Name:  name,
+			Body:  body,
+			Mode:  uint(out.filemodes[fx]),
+			Owner: out.fileowners[fx],
+			Group: out.filegroups[fx],
+			MTime: out.filemtimes[fx],
+			Type:  FileType(out.fileflags[fx]),
+		}
+		if fx < len(out.filerdevs) {
+			f.setRDev(out.filerdevs[fx])
+		}
+		if fx < len(out.fileverifyflags) {
+			f.NoVerify = VerifyAll &^ VerifyFlags(out.fileverifyflags[fx])
+		}
+		if fx < len(out.filecaps) {
+			f.Capabilities = out.filecaps[fx]
+		}
+		if fx < len(out.filelangs) {
+			f.Lang = out.filelangs[fx]
+		}
+		out.files[name] = f
+		return nil
+	})
+}
+
+// restoreHardLinks turns regular files sharing an inode back into hard links
+// to the member carrying the content, which is the last one.
+func restoreHardLinks(out *RPM) {
+	groups := map[int32][]string{}
+	for i, inode := range out.fileinodes {
+		if i >= len(out.filemodes) || out.filemodes[i]&0170000 != 0100000 || FileType(out.fileflags[i])&GhostFile != 0 {
+			continue
+		}
+		name := out.di.l[out.dirindexes[i]] + out.basenames[i]
+		groups[inode] = append(groups[inode], name)
+	}
+	for _, names := range groups {
+		target := names[len(names)-1]
+		for _, name := range names[:len(names)-1] {
+			f, ok := out.files[name]
+			if !ok {
+				continue
+			}
+			f.Body = nil
+			f.HardLink = target
+			out.files[name] = f
+		}
+	}
+}
+
 func readFiles(out *RPM, file *os.File) error {
 	payload := bytes.NewBuffer(nil)
 	count, err := payload.ReadFrom(file)
@@ -309,25 +453,34 @@ func readFiles(out *RPM, file *os.File) error {
 	}
 
 	out.files = map[string]RPMFile{}
-	r := cpio.NewReader(decompressPayload)
-	i := 0
-	for {
-		err = readFile(r, i, out)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
+	if bytes.HasPrefix(decompressPayload.Bytes(), []byte(strippedMagic)) {
+		if err := readStrippedFiles(decompressPayload.Bytes(), out); err != nil {
 			return err
 		}
-		i++
+	} else {
+		r := cpio.NewReader(decompressPayload)
+		i := 0
+		for {
+			err = readFile(r, i, out)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			i++
+		}
 	}
+	restoreHardLinks(out)
 	out.dirindexes = make([]uint32, 0)
 	out.basenames = make([]string, 0)
 	out.fileowners = make([]string, 0)
 	out.filegroups = make([]string, 0)
 	out.filemtimes = make([]uint32, 0)
 	out.fileflags = make([]uint32, 0)
-	out.filesizes = make([]uint32, 0)
+	out.filesizes = make([]uint64, 0)
+	out.fileinodes = make([]int32, 0)
+	out.filerdevs = make([]int16, 0)
 	out.filedigests = make([]string, 0)
 	out.filelinktos = make([]string, 0)
 	out.filemodes = make([]uint16, 0)
@@ -370,19 +523,24 @@ func ReadRPMFile(p string) (*RPM, error) {
 
 	readFileIndexes(out)
 
-	popTag(out.headers.entries, tagHeaderI18NTable, IndexEntry.toString)
 	popTag(out.headers.entries, tagPayloadFormat, IndexEntry.toString)
 	popTag(out.headers.entries, tagPayloadFlags, IndexEntry.toString)
 	popTag(out.headers.entries, tagPayloadDigest, IndexEntry.toStringArray)
-	popTag(out.headers.entries, tagPayloadDigestAlgo, IndexEntry.toUint32)
+	if algo, err := popTag(out.headers.entries, tagPayloadDigestAlgo, IndexEntry.toUint32); err == nil && out.DigestAlgorithm == "" {
+		out.DigestAlgorithm, _ = digestNameFromID(int32(algo))
+	}
 
 	err = readScripts(out)
 	if err != nil {
 		return nil, err
 	}
 
-	payloadSize, _ := popTag(out.headers.entries, tagSize, IndexEntry.toUint32)
-	out.payloadSize = uint(payloadSize)
+	if payloadSize, err := popTag(out.headers.entries, tagLongSize, IndexEntry.toUint64); err == nil {
+		out.payloadSize = payloadSize
+	} else {
+		payloadSize, _ := popTag(out.headers.entries, tagSize, IndexEntry.toUint32)
+		out.payloadSize = uint64(payloadSize)
+	}
 
 	out.customTags = out.headers.entries
 	out.headers.h = 0
@@ -393,18 +551,9 @@ func ReadRPMFile(p string) (*RPM, error) {
 		return nil, err
 	}
 
-	out.cpio = cpio.NewWriter(out.payload)
-
-	buff := &bytes.Buffer{}
-
-	z, _, err := setupCompressor(out.Compressor, buff)
-	if err != nil {
+	if err := out.resetPayload(); err != nil {
 		return nil, err
 	}
 
-	out.payload = buff
-	out.compressedPayload = z
-	out.cpio = cpio.NewWriter(z)
-
-	return out, err
+	return out, nil
 }
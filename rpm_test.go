@@ -2,10 +2,14 @@ package rpmpack
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -65,6 +69,53 @@ func Test100644(t *testing.T) {
 	}
 }
 
+func TestFileSource(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	content := "content of the file"
+	r.AddFile(RPMFile{
+		Name: "/usr/local/hello",
+		Mode: 0100644,
+		Source: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(content)), nil
+		},
+		Size: int64(len(content)),
+	})
+
+	if err := r.Write(ioutil.Discard); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	if r.filesizes[0] != uint64(len(content)) {
+		t.Errorf("file size want %d, got %d", len(content), r.filesizes[0])
+	}
+	if want := fmt.Sprintf("%x", sha256.Sum256([]byte(content))); r.filedigests[0] != want {
+		t.Errorf("file digest want %s, got %s", want, r.filedigests[0])
+	}
+	if r.payloadSize != uint64(len(content)) {
+		t.Errorf("payload size want %d, got %d", len(content), r.payloadSize)
+	}
+}
+
+func TestFileSourceSizeMismatch(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{
+		Name: "/usr/local/hello",
+		Source: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("short")), nil
+		},
+		Size: 100,
+	})
+
+	if err := r.Write(ioutil.Discard); err == nil {
+		t.Error("Write should fail when Source yields less than Size bytes")
+	}
+}
+
 func TestCompression(t *testing.T) {
 	testCases := []struct {
 		Type           string
@@ -245,7 +296,200 @@ func TestWriteThenReader(t *testing.T) {
 		t.Error("headers don't match")
 	}
 
-	if bytes.Equal(rpm.payload.Bytes(), r.payload.Bytes()) == false {
+	gotPayload, err := io.ReadAll(rpm.payload.Reader())
+	if err != nil {
+		t.Fatalf("Failed to read payload: %v", err)
+	}
+	wantPayload, err := io.ReadAll(r.payload.Reader())
+	if err != nil {
+		t.Fatalf("Failed to read payload: %v", err)
+	}
+	if bytes.Equal(gotPayload, wantPayload) == false {
 		t.Error("payload doesn't match")
 	}
 }
+
+func TestLargeFilesLayout(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "large"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{
+		Name: "/usr/local/dir",
+		Mode: 040755,
+	})
+	r.AddFile(RPMFile{
+		Name: "/usr/local/dir/hello",
+		Body: []byte("content of the file"),
+		Mode: 0100644,
+	})
+	// Files of 4GiB are too slow to compress in a test, force the layout
+	// used for them instead.
+	r.largeFiles = true
+
+	f, err := os.CreateTemp(t.TempDir(), "large-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	if _, ok := r.headers.entries[tagLongFileSizes]; !ok {
+		t.Error("LONGFILESIZES missing from header")
+	}
+	if _, ok := r.headers.entries[tagFileSizes]; ok {
+		t.Error("FILESIZES should not be written together with LONGFILESIZES")
+	}
+	wantReq := &Relation{Name: "rpmlib(LargeFiles)", Version: "4.12.0-1", Sense: SenseRPMLIB | SenseLess | SenseEqual}
+	found := false
+	for _, req := range r.Requires {
+		found = found || req.Equal(wantReq)
+	}
+	if !found {
+		t.Errorf("Requires %v is missing rpmlib(LargeFiles)", r.Requires.String())
+	}
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	if d := cmp.Diff("content of the file", string(got.files["/usr/local/dir/hello"].Body)); d != "" {
+		t.Errorf("file content differs (want->got):\n%v", d)
+	}
+	if _, ok := got.files["/usr/local/dir"]; !ok {
+		t.Error("directory missing from stripped payload")
+	}
+}
+
+func TestSpecialFiles(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "devices"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/dev/null", Mode: 020666, DevMajor: 1, DevMinor: 3})
+	r.AddFile(RPMFile{Name: "/dev/sda", Mode: 060660, DevMajor: 8})
+	r.AddFile(RPMFile{Name: "/run/fifo", Mode: 010600})
+	r.AddFile(RPMFile{Name: "/run/socket", Mode: 0140755})
+	r.AddFile(RPMFile{Name: "/run/file", Body: []byte("content")})
+	r.AllowListDirs(map[string]bool{})
+
+	f, err := os.CreateTemp(t.TempDir(), "devices-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	if d := cmp.Diff([]string{"null", "sda", "fifo", "file", "socket"}, r.basenames); d != "" {
+		t.Errorf("basenames differ (want->got):\n%v", d)
+	}
+	if d := cmp.Diff([]uint16{020666, 060660, 010600, 0100000, 0140755}, r.filemodes); d != "" {
+		t.Errorf("file modes differ (want->got):\n%v", d)
+	}
+	if d := cmp.Diff([]int16{0x0103, 0x0800, 0, 0, 0}, r.filerdevs); d != "" {
+		t.Errorf("rdevs differ (want->got):\n%v", d)
+	}
+	if d := cmp.Diff([]uint64{0, 0, 0, 7, 0}, r.filesizes); d != "" {
+		t.Errorf("file sizes differ (want->got):\n%v", d)
+	}
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	null := got.files["/dev/null"]
+	if null.DevMajor != 1 || null.DevMinor != 3 {
+		t.Errorf("/dev/null device want 1,3 got %d,%d", null.DevMajor, null.DevMinor)
+	}
+	if sda := got.files["/dev/sda"]; sda.DevMajor != 8 || sda.DevMinor != 0 {
+		t.Errorf("/dev/sda device want 8,0 got %d,%d", sda.DevMajor, sda.DevMinor)
+	}
+}
+
+func TestSpecialFilesLargeDevice(t *testing.T) {
+	for _, f := range []RPMFile{
+		{Name: "/dev/nvme0n1", Mode: 060660, DevMajor: 259},
+		{Name: "/dev/loop256", Mode: 060660, DevMajor: 7, DevMinor: 256},
+	} {
+		r, err := NewRPM(RPMMetaData{Name: "devices"})
+		if err != nil {
+			t.Fatalf("NewRPM returned error %v", err)
+		}
+		r.AddFile(f)
+		if err := r.Write(io.Discard); err == nil {
+			t.Errorf("Write of device %d,%d returned no error", f.DevMajor, f.DevMinor)
+		}
+	}
+}
+
+func TestSourceRPM(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{
+		Name:     "foo",
+		Version:  "1.0",
+		Release:  "1",
+		IsSource: true,
+		Sources:  []string{"foo-1.0.tar.gz"},
+		Patches:  []string{"fix.patch"},
+	})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{Name: "foo.spec", Body: []byte("Name: foo\n"), Type: SpecFile})
+	r.AddFile(RPMFile{Name: "/foo-1.0.tar.gz", Body: []byte("tarball")})
+	r.AddFile(RPMFile{Name: "fix.patch", Body: []byte("patch")})
+
+	f, err := os.CreateTemp(t.TempDir(), "foo-*.src.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	if got.lead.typeFile != 1 {
+		t.Errorf("lead type want 1 (source), got %d", got.lead.typeFile)
+	}
+	if !got.IsSource || got.SourcePackage != "" {
+		t.Errorf("IsSource, SourcePackage want true, \"\", got %v, %q", got.IsSource, got.SourcePackage)
+	}
+	if d := cmp.Diff([]string{"foo-1.0.tar.gz"}, got.Sources); d != "" {
+		t.Errorf("Sources differ (want->got):\n%v", d)
+	}
+	if d := cmp.Diff([]string{"fix.patch"}, got.Patches); d != "" {
+		t.Errorf("Patches differ (want->got):\n%v", d)
+	}
+	for _, rel := range got.Provides {
+		t.Errorf("source rpm provides %v", rel)
+	}
+	if spec := got.files["foo.spec"]; spec.Type != SpecFile || string(spec.Body) != "Name: foo\n" {
+		t.Errorf("foo.spec want a SpecFile with its content, got %+v", spec)
+	}
+	if _, ok := got.files["foo-1.0.tar.gz"]; !ok {
+		t.Errorf("missing foo-1.0.tar.gz, got %v", got.files)
+	}
+	for _, rel := range got.Requires {
+		if rel.Name == "rpmlib(PayloadFilesHavePrefix)" {
+			t.Errorf("source rpm requires %v", rel)
+		}
+	}
+}
+
+func TestSourceRPMFlatPaths(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "foo", IsSource: true})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/src/foo.spec"})
+	if err := r.Write(io.Discard); !errors.Is(err, ErrSourceFilePath) {
+		t.Errorf("Write want ErrSourceFilePath, got %v", err)
+	}
+}
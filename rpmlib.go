@@ -0,0 +1,117 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import "strings"
+
+// rpmlibFeature is a feature of rpm packages may depend on, with the version
+// of rpm which introduced it.
+// https://github.com/rpm-software-management/rpm/blob/master/lib/rpmds.c
+type rpmlibFeature struct {
+	name, version string
+}
+
+var (
+	featureCompressedFileNames      = rpmlibFeature{"CompressedFileNames", "3.0.4-1"}
+	featurePayloadFilesHavePrefix   = rpmlibFeature{"PayloadFilesHavePrefix", "4.0-1"}
+	featureScriptletInterpreterArgs = rpmlibFeature{"ScriptletInterpreterArgs", "4.0.3-1"}
+	featureBuiltinLuaScripts        = rpmlibFeature{"BuiltinLuaScripts", "4.2.2-1"}
+	featurePayloadIsLzma            = rpmlibFeature{"PayloadIsLzma", "4.4.2-1"}
+	featureFileDigests              = rpmlibFeature{"FileDigests", "4.6.0-1"}
+	featureFileCaps                 = rpmlibFeature{"FileCaps", "4.6.1-1"}
+	featurePayloadIsXz              = rpmlibFeature{"PayloadIsXz", "5.2-1"}
+	featurePayloadIsZstd            = rpmlibFeature{"PayloadIsZstd", "5.4.18-1"}
+	featureTildeInVersions          = rpmlibFeature{"TildeInVersions", "4.10.0-1"}
+	featureLargeFiles               = rpmlibFeature{"LargeFiles", "4.12.0-1"}
+	featureRichDependencies         = rpmlibFeature{"RichDependencies", "4.12.0-1"}
+	featureFileTriggers             = rpmlibFeature{"FileTriggers", "4.13.0-1"}
+	featureCaretInVersions          = rpmlibFeature{"CaretInVersions", "4.15.0-1"}
+)
+
+// relation returns the requirement on the feature.
+func (f rpmlibFeature) relation() *Relation {
+	return &Relation{
+		Name:    "rpmlib(" + f.name + ")",
+		Version: f.version,
+		Sense:   SenseRPMLIB | SenseLess | SenseEqual,
+	}
+}
+
+// relations returns all the dependencies of the package.
+func (r *RPM) relations() []Relations {
+	return []Relations{
+		r.Provides, r.Obsoletes, r.Suggests, r.Recommends, r.Requires, r.Conflicts,
+		r.OrderWithRequires, r.Supplements, r.Enhances,
+	}
+}
+
+// rpmlibFeatures returns the rpm features used by the package, in a stable
+// order. It must be called once all files are added and largeFiles is set.
+func (r *RPM) rpmlibFeatures() []rpmlibFeature {
+	// Every package stores directory names and basenames apart, and binary
+	// packages prefix payload file names with ".".
+	features := []rpmlibFeature{featureCompressedFileNames}
+	add := func(f rpmlibFeature, used bool) {
+		if used {
+			features = append(features, f)
+		}
+	}
+
+	var interpreterArgs, lua bool
+	for _, s := range r.allScriptlets() {
+		interpreterArgs = interpreterArgs || len(s.Interpreter) > 1
+		lua = lua || s.isLua()
+	}
+	var caps bool
+	for _, f := range r.files {
+		caps = caps || f.Capabilities != ""
+	}
+	rich := false
+	versions := []string{r.Version, r.Release}
+	for _, rels := range r.relations() {
+		for _, rel := range rels {
+			rich = rich || strings.HasPrefix(rel.Name, "(")
+			versions = append(versions, rel.Version)
+		}
+	}
+	var tilde, caret bool
+	for _, v := range versions {
+		tilde = tilde || strings.Contains(v, "~")
+		caret = caret || strings.Contains(v, "^")
+	}
+
+	add(featurePayloadFilesHavePrefix, !r.IsSource)
+	add(featureScriptletInterpreterArgs, interpreterArgs)
+	add(featureBuiltinLuaScripts, lua)
+	add(featurePayloadIsLzma, r.Compressor == "lzma")
+	add(featureFileDigests, r.digest.id != hashAlgoMD5)
+	add(featureFileCaps, caps)
+	add(featurePayloadIsXz, r.Compressor == "xz")
+	add(featurePayloadIsZstd, r.Compressor == "zstd")
+	add(featureTildeInVersions, tilde)
+	add(featureLargeFiles, r.largeFiles)
+	add(featureRichDependencies, rich)
+	add(featureFileTriggers, len(r.fileTriggers) > 0 || len(r.transFileTriggers) > 0)
+	add(featureCaretInVersions, caret)
+	return features
+}
+
+// addRPMLibRequires adds a requirement on every rpm feature the package uses,
+// so older versions of rpm refuse to install it.
+func (r *RPM) addRPMLibRequires() {
+	for _, f := range r.rpmlibFeatures() {
+		r.Requires.addIfMissing(f.relation())
+	}
+}
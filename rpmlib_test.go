@@ -0,0 +1,130 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRPMLibRequires(t *testing.T) {
+	base := []string{"rpmlib(CompressedFileNames)", "rpmlib(PayloadFilesHavePrefix)"}
+	testCases := []struct {
+		name  string
+		md    RPMMetaData
+		setup func(r *RPM)
+		want  []string
+	}{{
+		name: "md5 gzip package",
+		md:   RPMMetaData{DigestAlgorithm: "md5"},
+		want: base,
+	}, {
+		name: "default digest",
+		want: append(base, "rpmlib(FileDigests)"),
+	}, {
+		name: "zstd",
+		md:   RPMMetaData{DigestAlgorithm: "md5", Compressor: "zstd:fastest"},
+		want: append(base, "rpmlib(PayloadIsZstd)"),
+	}, {
+		name: "xz",
+		md:   RPMMetaData{DigestAlgorithm: "md5", Compressor: "xz"},
+		want: append(base, "rpmlib(PayloadIsXz)"),
+	}, {
+		name: "lzma",
+		md:   RPMMetaData{DigestAlgorithm: "md5", Compressor: "lzma"},
+		want: append(base, "rpmlib(PayloadIsLzma)"),
+	}, {
+		name: "rich dependencies",
+		md: RPMMetaData{DigestAlgorithm: "md5", Recommends: Relations{
+			{Name: "(foo if bar)"},
+		}},
+		want: append(base, "rpmlib(RichDependencies)"),
+	}, {
+		name: "tilde and caret versions",
+		md: RPMMetaData{DigestAlgorithm: "md5", Version: "1.0~rc1", Requires: Relations{
+			{Name: "foo", Version: "2.0^git1", Sense: SenseGreater | SenseEqual},
+		}},
+		want: append(base, "rpmlib(TildeInVersions)", "rpmlib(CaretInVersions)"),
+	}, {
+		name: "scriptlets",
+		md:   RPMMetaData{DigestAlgorithm: "md5"},
+		setup: func(r *RPM) {
+			r.AddPostin("echo hi", "/bin/bash", "-e")
+			r.AddPreun("print('hi')", LuaInterpreter)
+		},
+		want: append(base, "rpmlib(ScriptletInterpreterArgs)", "rpmlib(BuiltinLuaScripts)"),
+	}, {
+		name: "file features",
+		md:   RPMMetaData{DigestAlgorithm: "md5"},
+		setup: func(r *RPM) {
+			r.AddFile(RPMFile{Name: "/usr/bin/ping", Body: []byte("ping"), Capabilities: "cap_net_raw=ep"})
+			r.AddFileTrigger(FileTrigger{Type: TriggerIn, Prefixes: []string{"/usr/lib/plugins"}})
+		},
+		want: append(base, "rpmlib(FileCaps)", "rpmlib(FileTriggers)"),
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRPM(tc.md)
+			if err != nil {
+				t.Fatalf("NewRPM returned error %v", err)
+			}
+			if tc.setup != nil {
+				tc.setup(r)
+			}
+			if err := r.Write(io.Discard); err != nil {
+				t.Fatalf("Write returned error %v", err)
+			}
+			var got []string
+			for _, req := range r.Requires {
+				if req.Sense&SenseRPMLIB == 0 {
+					continue
+				}
+				got = append(got, req.Name)
+				if want := SenseRPMLIB | SenseLess | SenseEqual; req.Sense != want {
+					t.Errorf("%s sense = %#x, want %#x", req.Name, uint32(req.Sense), uint32(want))
+				}
+				if req.Version == "" {
+					t.Errorf("%s has no version", req.Name)
+				}
+			}
+			if d := cmp.Diff(tc.want, got); d != "" {
+				t.Errorf("rpmlib requires differ (want->got):\n%v", d)
+			}
+		})
+	}
+}
+
+func TestRPMLibRequiresOnce(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.Requires = append(r.Requires, featureFileDigests.relation())
+	if err := r.Write(io.Discard); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	count := 0
+	for _, req := range r.Requires {
+		if req.Name == "rpmlib(FileDigests)" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("rpmlib(FileDigests) required %d times, want 1", count)
+	}
+}
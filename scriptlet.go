@@ -0,0 +1,84 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import "fmt"
+
+// LuaInterpreter runs a scriptlet with the lua interpreter embedded in rpm.
+const LuaInterpreter = "<lua>"
+
+// Scriptlet is a script run by rpm, together with its interpreter.
+type Scriptlet struct {
+	// Body is the content of the script.
+	Body string
+	// Interpreter is the program running the script and its arguments,
+	// e.g. `["/usr/bin/bash", "-e"]` or `["<lua>"]`. Empty means /bin/sh.
+	Interpreter []string
+}
+
+// NewScriptlet returns a scriptlet running body with interpreter.
+func NewScriptlet(body string, interpreter ...string) Scriptlet {
+	return Scriptlet{Body: body, Interpreter: interpreter}
+}
+
+// empty reports whether there is nothing to run. A scriptlet can run an
+// interpreter without a body, like `%post -p /sbin/ldconfig`.
+func (s Scriptlet) empty() bool {
+	return s.Body == "" && len(s.Interpreter) == 0
+}
+
+// isLua reports whether the scriptlet runs with the embedded lua interpreter.
+func (s Scriptlet) isLua() bool {
+	return len(s.Interpreter) > 0 && s.Interpreter[0] == LuaInterpreter
+}
+
+// progEntry returns the entry of the interpreter tag. Like rpmbuild, a lone
+// interpreter is a string, and an interpreter with arguments a string array.
+func (s Scriptlet) progEntry() IndexEntry {
+	switch len(s.Interpreter) {
+	case 0:
+		return EntryString(BIN_SH)
+	case 1:
+		return EntryString(s.Interpreter[0])
+	}
+	return EntryStringSlice(s.Interpreter)
+}
+
+// AddToIndex adds the scriptlet to the script and interpreter tags.
+func (s Scriptlet) AddToIndex(h *index, scriptTag, progTag int) {
+	if s.empty() {
+		return
+	}
+	if s.Body != "" {
+		h.Add(scriptTag, EntryString(s.Body))
+	}
+	h.Add(progTag, s.progEntry())
+}
+
+// interpreterFromEntry reads an interpreter tag, which is either a string or
+// a string array.
+func interpreterFromEntry(e IndexEntry) ([]string, error) {
+	switch e.rpmtype {
+	case typeString:
+		prog, err := e.toString()
+		if err != nil {
+			return nil, err
+		}
+		return []string{prog}, nil
+	case typeStringArray:
+		return e.toStringArray()
+	}
+	return nil, fmt.Errorf("rpmtype %d is not a valid interpreter type", e.rpmtype)
+}
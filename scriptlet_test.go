@@ -0,0 +1,127 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScriptletEntries(t *testing.T) {
+	testCases := []struct {
+		name       string
+		scriptlet  Scriptlet
+		wantScript bool
+		wantType   int
+		wantProg   []string
+	}{{
+		name:       "default shell",
+		scriptlet:  NewScriptlet("echo hi"),
+		wantScript: true,
+		wantType:   typeString,
+		wantProg:   []string{"/bin/sh"},
+	}, {
+		name:       "lua",
+		scriptlet:  NewScriptlet("print('hi')", LuaInterpreter),
+		wantScript: true,
+		wantType:   typeString,
+		wantProg:   []string{"<lua>"},
+	}, {
+		name:       "interpreter with arguments",
+		scriptlet:  NewScriptlet("echo hi", "/usr/bin/bash", "-e"),
+		wantScript: true,
+		wantType:   typeStringArray,
+		wantProg:   []string{"/usr/bin/bash", "-e"},
+	}, {
+		name:      "interpreter without body",
+		scriptlet: NewScriptlet("", "/sbin/ldconfig"),
+		wantType:  typeString,
+		wantProg:  []string{"/sbin/ldconfig"},
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			h := newIndex(immutable)
+			tc.scriptlet.AddToIndex(h, tagPostin, tagPostinProg)
+			if _, ok := h.entries[tagPostin]; ok != tc.wantScript {
+				t.Errorf("script tag present = %v, want %v", ok, tc.wantScript)
+			}
+			prog := h.entries[tagPostinProg]
+			if prog.rpmtype != tc.wantType {
+				t.Errorf("interpreter tag type = %d, want %d", prog.rpmtype, tc.wantType)
+			}
+			got, err := interpreterFromEntry(prog)
+			if err != nil {
+				t.Fatalf("interpreterFromEntry returned error %v", err)
+			}
+			if d := cmp.Diff(tc.wantProg, got); d != "" {
+				t.Errorf("interpreter differs (want->got):\n%v", d)
+			}
+		})
+	}
+	h := newIndex(immutable)
+	Scriptlet{}.AddToIndex(h, tagPostin, tagPostinProg)
+	if len(h.entries) != 0 {
+		t.Errorf("empty scriptlet should not add entries, got %v", h.entries)
+	}
+}
+
+func TestScriptletsRoundTrip(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "scripts"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddPrein("echo prein")
+	r.AddPostin("print('postin')", LuaInterpreter)
+	r.AddPreun("import sys", "/usr/bin/python3", "-s")
+	r.AddPostun("", "/sbin/ldconfig")
+
+	f, err := os.CreateTemp(t.TempDir(), "scripts-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	lua := &Relation{Name: "rpmlib(BuiltinLuaScripts)", Version: "4.2.2-1", Sense: SenseRPMLIB | SenseLess | SenseEqual}
+	found := false
+	for _, req := range r.Requires {
+		found = found || req.Equal(lua)
+	}
+	if !found {
+		t.Errorf("Requires %v is missing rpmlib(BuiltinLuaScripts)", r.Requires.String())
+	}
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	want := []Scriptlet{
+		{},
+		{Body: "echo prein", Interpreter: []string{"/bin/sh"}},
+		{Body: "print('postin')", Interpreter: []string{"<lua>"}},
+		{Body: "import sys", Interpreter: []string{"/usr/bin/python3", "-s"}},
+		{Interpreter: []string{"/sbin/ldconfig"}},
+		{},
+		{},
+	}
+	if d := cmp.Diff(want, got.scriptlets()); d != "" {
+		t.Errorf("scriptlets differ (want->got):\n%v", d)
+	}
+}
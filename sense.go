@@ -39,7 +39,8 @@ const (
 	unused3
 	unused4
 
-	SenseRPMLIB rpmSense = 1 << 24
+	SenseRPMLIB  rpmSense = 1 << 24
+	TriggerPrein rpmSense = 1 << 25
 )
 
 var relationMatch = regexp.MustCompile(`([^=<>\s]*)\s*((?:=|>|<)*)\s*(.*)?`)
@@ -62,6 +63,9 @@ func (r *Relation) String() string {
 
 // Equal compare the equality of two relations
 func (r *Relation) Equal(o *Relation) bool {
+	if r == nil || o == nil {
+		return r == o
+	}
 	return r.Name == o.Name && r.Version == o.Version && r.Sense == o.Sense
 }
 
@@ -134,9 +138,13 @@ func NewRelation(related string) (*Relation, error) {
 		version string
 	)
 
-	if strings.HasPrefix(related, "(") && strings.HasSuffix(related, ")") {
-		// This is a `rich` dependency which must be parsed at install time
+	if strings.HasPrefix(related, "(") {
+		// This is a `rich` dependency which must be parsed at install time,
+		// it is stored as is once it is known to be valid.
 		// https://rpm-software-management.github.io/rpm/manual/boolean_dependencies.html
+		if _, err := ParseRichDep(related); err != nil {
+			return nil, err
+		}
 		sense = SenseAny
 		name = related
 	} else {
@@ -164,6 +172,10 @@ var stringToSense = map[string]rpmSense{
 	">=": SenseGreater | SenseEqual,
 }
 
+// senseCompare are the bits of the version comparison, the others are
+// qualifiers like ScriptPost.
+const senseCompare = SenseLess | SenseGreater | SenseEqual
+
 // String return the string representation of the rpmSense
 func (r rpmSense) String() string {
 	var (
@@ -172,7 +184,7 @@ func (r rpmSense) String() string {
 	)
 
 	for ret, val = range stringToSense {
-		if r == val {
+		if r&senseCompare == val {
 			return ret
 		}
 	}
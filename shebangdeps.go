@@ -0,0 +1,145 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// maxShebang is the longest `#!` line looked at, like the kernel's
+// BINPRM_BUF_SIZE.
+const maxShebang = 256
+
+// ShebangRewrites maps programs run through env to what they require. It
+// implements flag.Value, taking `NAME=REQUIREMENT`, e.g. `python3=/usr/bin/python3`.
+type ShebangRewrites map[string]string
+
+// String implements flag.Value.
+func (s *ShebangRewrites) String() string {
+	names := make([]string, 0, len(*s))
+	for name := range *s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] += "=" + (*s)[name]
+	}
+	return strings.Join(names, ",")
+}
+
+// Set implements flag.Value.
+func (s *ShebangRewrites) Set(value string) error {
+	name, req, ok := strings.Cut(value, "=")
+	if !ok || name == "" || req == "" {
+		return fmt.Errorf("invalid rewrite %q, want NAME=REQUIREMENT", value)
+	}
+	if _, err := NewRelation(req); err != nil {
+		return err
+	}
+	if *s == nil {
+		*s = ShebangRewrites{}
+	}
+	(*s)[name] = req
+	return nil
+}
+
+// ShebangDependencies is a DependencyGenerator for executable scripts. The
+// interpreter of the `#!` line is required by path, like rpm's script.req,
+// e.g. `/usr/bin/python3`. Scripts run through env, as in
+// `#!/usr/bin/env perl`, also require the program, by name unless
+// EnvRewrites says otherwise.
+type ShebangDependencies struct {
+	// EnvRewrites maps the programs run through env to requirements, e.g.
+	// `perl` to `/usr/bin/perl` or `python3` to `python3 >= 3.9`.
+	EnvRewrites ShebangRewrites
+	// Exclude lists interpreters and programs which are not required, as
+	// path.Match patterns, e.g. `/bin/sh` or `/usr/bin/env`.
+	Exclude []string
+}
+
+// Generate implements DependencyGenerator.
+func (s ShebangDependencies) Generate(f RPMFile, content *io.SectionReader) (provides, requires Relations, err error) {
+	if f.Mode&0111 == 0 {
+		return nil, nil, nil
+	}
+	head := make([]byte, maxShebang)
+	n, err := content.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	head = head[:n]
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return nil, nil, nil
+	}
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+	fields := strings.Fields(strings.TrimSuffix(string(head[2:]), "\r"))
+	// Relative interpreters depend on the working directory.
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return nil, nil, nil
+	}
+	add := func(req string) error {
+		if s.isExcluded(req) {
+			return nil
+		}
+		rel, err := NewRelation(req)
+		if err != nil {
+			return err
+		}
+		requires.addIfMissing(rel)
+		return nil
+	}
+	if err := add(fields[0]); err != nil {
+		return nil, nil, err
+	}
+	if path.Base(fields[0]) != "env" {
+		return nil, requires, nil
+	}
+	// Skip the options and variable assignments of env, e.g.
+	// `#!/usr/bin/env -S LANG=C python3 -u`.
+	for _, arg := range fields[1:] {
+		if strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+			continue
+		}
+		if s.isExcluded(arg) {
+			break
+		}
+		req, ok := s.EnvRewrites[arg]
+		if !ok {
+			req = arg
+		}
+		if err := add(req); err != nil {
+			return nil, nil, err
+		}
+		break
+	}
+	return nil, requires, nil
+}
+
+// isExcluded reports whether the program matches an exclude pattern.
+func (s ShebangDependencies) isExcluded(name string) bool {
+	for _, pattern := range s.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
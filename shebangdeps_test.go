@@ -0,0 +1,89 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestShebangDependencies(t *testing.T) {
+	g := ShebangDependencies{
+		EnvRewrites: ShebangRewrites{"python3": "/usr/bin/python3", "node": "nodejs >= 18"},
+		Exclude:     []string{"/usr/bin/env", "/bin/sh", "ruby*"},
+	}
+	testCases := []struct {
+		name, body string
+		mode       uint
+		want       Relations
+	}{
+		{"path", "#!/usr/bin/python3\nprint(1)\n", 0755, Relations{{Name: "/usr/bin/python3"}}},
+		{"arguments", "#! /usr/bin/perl -w\n", 0755, Relations{{Name: "/usr/bin/perl"}}},
+		{"crlf", "#!/bin/bash\r\n", 0755, Relations{{Name: "/bin/bash"}}},
+		{"env by name", "#!/usr/bin/env perl\n", 0755, Relations{{Name: "perl"}}},
+		{"env rewrite", "#!/usr/bin/env python3\n", 0755, Relations{{Name: "/usr/bin/python3"}}},
+		{"env versioned rewrite", "#!/usr/bin/env node\n", 0755, Relations{{Name: "nodejs", Version: "18", Sense: SenseGreater | SenseEqual}}},
+		{"env options", "#!/usr/bin/env -S LANG=C perl -w\n", 0755, Relations{{Name: "perl"}}},
+		{"env excluded", "#!/usr/bin/env ruby3.2\n", 0755, nil},
+		{"excluded", "#!/bin/sh\n", 0755, nil},
+		{"relative", "#!python\n", 0755, nil},
+		{"not executable", "#!/usr/bin/python3\n", 0644, nil},
+		{"not a script", "\x7fELF", 0755, nil},
+		{"empty", "", 0755, nil},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, requires, err := g.Generate(RPMFile{Mode: tc.mode}, io.NewSectionReader(strings.NewReader(tc.body), 0, int64(len(tc.body))))
+			if err != nil {
+				t.Fatalf("Generate returned err: %v", err)
+			}
+			if d := cmp.Diff(tc.want, requires); d != "" {
+				t.Errorf("requires differ (want->got):\n%v", d)
+			}
+		})
+	}
+}
+
+func TestShebangDependenciesAllRequired(t *testing.T) {
+	body := "#!/usr/bin/env perl\n"
+	_, requires, err := ShebangDependencies{}.Generate(RPMFile{Mode: 0755}, io.NewSectionReader(strings.NewReader(body), 0, int64(len(body))))
+	if err != nil {
+		t.Fatalf("Generate returned err: %v", err)
+	}
+	if d := cmp.Diff(Relations{{Name: "/usr/bin/env"}, {Name: "perl"}}, requires); d != "" {
+		t.Errorf("requires differ (want->got):\n%v", d)
+	}
+}
+
+func TestShebangRewrites(t *testing.T) {
+	var s ShebangRewrites
+	for _, v := range []string{"python3=/usr/bin/python3", "perl=perl(:MODULE_COMPAT_5.36.0)"} {
+		if err := s.Set(v); err != nil {
+			t.Fatalf("Set(%q) returned err: %v", v, err)
+		}
+	}
+	if got, want := s.String(), "perl=perl(:MODULE_COMPAT_5.36.0),python3=/usr/bin/python3"; got != want {
+		t.Errorf("String() want %q, got %q", want, got)
+	}
+	for _, v := range []string{"python3", "=foo", "foo=", "foo=(bar or"} {
+		if err := s.Set(v); err == nil {
+			t.Errorf("Set(%q) returned no error", v)
+		}
+	}
+}
@@ -0,0 +1,969 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnsupportedSpec is returned for spec file constructs which rpmpack
+	// does not handle, like %build or %if.
+	ErrUnsupportedSpec = errors.New("unsupported spec construct")
+	// ErrSpecFileNotFound is returned when a path of %files matches nothing
+	// in the buildroot.
+	ErrSpecFileNotFound = errors.New("file not found")
+	// ErrUnpackagedFiles is returned when the buildroot has files which are
+	// not listed in %files, like rpmbuild does.
+	ErrUnpackagedFiles = errors.New("installed but unpackaged files")
+)
+
+// SpecError is an error at a line of a spec file.
+type SpecError struct {
+	Line int
+	Err  error
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *SpecError) Unwrap() error {
+	return e.Err
+}
+
+// maxMacroDepth limits the nesting of macro expansions, to catch macros
+// which expand to themselves.
+const maxMacroDepth = 64
+
+// specDefaultMacros are the directory macros of rpm, as set up for x86_64.
+// They can be overridden by the defines of ParseSpec, e.g. `_lib` on 32 bit
+// architectures.
+var specDefaultMacros = map[string]string{
+	"_prefix":         "/usr",
+	"_exec_prefix":    "%{_prefix}",
+	"_bindir":         "%{_exec_prefix}/bin",
+	"_sbindir":        "%{_exec_prefix}/sbin",
+	"_libexecdir":     "%{_exec_prefix}/libexec",
+	"_lib":            "lib64",
+	"_libdir":         "%{_exec_prefix}/%{_lib}",
+	"_datadir":        "%{_prefix}/share",
+	"_includedir":     "%{_prefix}/include",
+	"_infodir":        "%{_datadir}/info",
+	"_mandir":         "%{_datadir}/man",
+	"_docdir":         "%{_datadir}/doc",
+	"_licensedir":     "%{_datadir}/licenses",
+	"_sysconfdir":     "/etc",
+	"_localstatedir":  "/var",
+	"_sharedstatedir": "/var/lib",
+	"_rundir":         "/run",
+	"_unitdir":        "/usr/lib/systemd/system",
+	"nil":             "",
+}
+
+// specScriptlets maps the scriptlet sections to the scriptlets they add.
+var specScriptlets = map[string]string{
+	"%pretrans":     "pretrans",
+	"%pre":          "prein",
+	"%post":         "postin",
+	"%preun":        "preun",
+	"%postun":       "postun",
+	"%posttrans":    "posttrans",
+	"%verifyscript": "verifyscript",
+}
+
+// unsupportedSpecSections explain why a section of a spec file can't be
+// read.
+var unsupportedSpecSections = map[string]string{
+	"%package":                "subpackages are not supported",
+	"%prep":                   "rpmpack packages an existing buildroot and does not build",
+	"%generate_buildrequires": "rpmpack packages an existing buildroot and does not build",
+	"%conf":                   "rpmpack packages an existing buildroot and does not build",
+	"%build":                  "rpmpack packages an existing buildroot and does not build",
+	"%install":                "rpmpack packages an existing buildroot and does not build",
+	"%check":                  "rpmpack packages an existing buildroot and does not build",
+	"%clean":                  "rpmpack packages an existing buildroot and does not build",
+	"%sourcelist":             "rpmpack packages an existing buildroot and does not build",
+	"%patchlist":              "rpmpack packages an existing buildroot and does not build",
+	"%trigger":                "triggers are not supported",
+	"%triggerprein":           "triggers are not supported",
+	"%triggerin":              "triggers are not supported",
+	"%triggerun":              "triggers are not supported",
+	"%triggerpostun":          "triggers are not supported",
+	"%filetriggerin":          "triggers are not supported",
+	"%filetriggerun":          "triggers are not supported",
+	"%filetriggerpostun":      "triggers are not supported",
+	"%transfiletriggerin":     "triggers are not supported",
+	"%transfiletriggerun":     "triggers are not supported",
+	"%transfiletriggerpostun": "triggers are not supported",
+	"%if":                     "conditionals are not supported",
+	"%ifarch":                 "conditionals are not supported",
+	"%ifnarch":                "conditionals are not supported",
+	"%ifos":                   "conditionals are not supported",
+	"%ifnos":                  "conditionals are not supported",
+	"%elif":                   "conditionals are not supported",
+	"%else":                   "conditionals are not supported",
+	"%endif":                  "conditionals are not supported",
+	"%include":                "includes are not supported",
+}
+
+// specIgnoredTags are preamble tags which only matter to building the
+// package.
+var specIgnoredTags = map[string]bool{
+	"source":         true,
+	"patch":          true,
+	"nosource":       true,
+	"nopatch":        true,
+	"buildroot":      true,
+	"buildrequires":  true,
+	"buildconflicts": true,
+	"autoreq":        true,
+	"autoprov":       true,
+	"autoreqprov":    true,
+	"excludearch":    true,
+	"exclusivearch":  true,
+	"excludeos":      true,
+	"exclusiveos":    true,
+}
+
+var specTagRE = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9]*)(\([^)]*\))?\s*:\s*(.*)$`)
+
+// specAttr are the attributes of %attr and %defattr. A negative mode and an
+// empty owner or group keep the attribute of the buildroot.
+type specAttr struct {
+	mode, dirMode int
+	owner, group  string
+}
+
+// specFile is a line of %files.
+type specFile struct {
+	line     int
+	path     string
+	attr     specAttr
+	fileType FileType
+	dir      bool
+	exclude  bool
+	noVerify *VerifyFlags
+	lang     string
+	caps     string
+}
+
+// Spec is a spec file read by ParseSpec. It describes a single package built
+// from a buildroot, which is given as a directory or a tar file.
+type Spec struct {
+	// Metadata holds the preamble, %description and %changelog.
+	Metadata RPMMetaData
+
+	scriptlets map[string]Scriptlet
+	files      []specFile
+}
+
+// specParser holds the state of ParseSpec.
+type specParser struct {
+	spec    *Spec
+	macros  map[string]string
+	line    int
+	defattr specAttr
+
+	// The current section, its arguments, the line it starts at and its
+	// body. The preamble has no name.
+	section      string
+	sectionArgs  []string
+	sectionLine  int
+	body         []string
+	seenSections map[string]bool
+}
+
+// ParseSpec reads a spec file. It understands the preamble, %description,
+// %files, the scriptlet sections and %changelog, with %define and %global
+// macros. defines are macros set before the spec is read, like
+// `rpmbuild --define`, e.g. `dist` to `.el9`.
+//
+// Spec files describing how to build, with subpackages, triggers or
+// conditionals are rejected with ErrUnsupportedSpec.
+func ParseSpec(inp io.Reader, defines map[string]string) (*Spec, error) {
+	p := &specParser{
+		spec:         &Spec{scriptlets: map[string]Scriptlet{}},
+		macros:       map[string]string{},
+		defattr:      specAttr{mode: -1, dirMode: -1, owner: "root", group: "root"},
+		seenSections: map[string]bool{},
+	}
+	for k, v := range specDefaultMacros {
+		p.macros[k] = v
+	}
+	for k, v := range defines {
+		p.macros[k] = v
+	}
+
+	s := bufio.NewScanner(inp)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		p.line++
+		line := s.Text()
+		// Macro definitions continue on the next line after a backslash.
+		if isSpecDefine(line) {
+			start := p.line
+			for strings.HasSuffix(line, `\`) && s.Scan() {
+				p.line++
+				line = strings.TrimSuffix(line, `\`) + "\n" + s.Text()
+			}
+			if err := p.define(line); err != nil {
+				return nil, &SpecError{Line: start, Err: err}
+			}
+			continue
+		}
+		if err := p.parseLine(line); err != nil {
+			return nil, &SpecError{Line: p.line, Err: err}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read spec file: %w", err)
+	}
+	if err := p.endSection(); err != nil {
+		return nil, &SpecError{Line: p.sectionLine, Err: err}
+	}
+	if p.spec.Metadata.Name == "" || p.spec.Metadata.Version == "" {
+		return nil, errors.New("spec file needs Name and Version tags")
+	}
+	return p.spec, nil
+}
+
+// isSpecDefine reports whether the line changes a macro.
+func isSpecDefine(line string) bool {
+	f := strings.Fields(line)
+	return len(f) > 0 && (f[0] == "%define" || f[0] == "%global" || f[0] == "%undefine")
+}
+
+// define handles %define, %global and %undefine. Like rpm, %define expands
+// its body when the macro is used and %global when it is defined.
+func (p *specParser) define(line string) error {
+	directive, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	rest = strings.TrimLeft(rest, " \t")
+	name, body, _ := strings.Cut(rest, " ")
+	if i := strings.IndexAny(name, "\t\n"); i >= 0 {
+		name, body = name[:i], name[i+1:]+" "+body
+	}
+	body = strings.TrimSpace(body)
+	if strings.HasSuffix(name, ")") {
+		return fmt.Errorf("%w: %s, parametric macros are not supported", ErrUnsupportedSpec, name)
+	}
+	if !isMacroName(name) {
+		return fmt.Errorf("invalid macro name %q", name)
+	}
+	switch directive {
+	case "%undefine":
+		delete(p.macros, name)
+		return nil
+	case "%global":
+		var err error
+		if body, err = p.expand(body); err != nil {
+			return err
+		}
+	}
+	if body == "" {
+		return fmt.Errorf("macro %s has no body", name)
+	}
+	p.macros[name] = body
+	return nil
+}
+
+func isMacroName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !isAlpha(c) && c != '_' && (i == 0 || !isDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// expand expands the macros of s. Undefined macros are kept as they are,
+// like rpm does.
+func (p *specParser) expand(s string) (string, error) {
+	return p.expandDepth(s, 0)
+}
+
+func (p *specParser) expandDepth(s string, depth int) (string, error) {
+	if depth > maxMacroDepth {
+		return "", errors.New("too deep macro recursion")
+	}
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '%' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		switch c := s[i+1]; {
+		case c == '%':
+			b.WriteByte('%')
+			i += 2
+		case c == '(':
+			return "", fmt.Errorf("%w: shell expansion %%(...)", ErrUnsupportedSpec)
+		case c == '[':
+			return "", fmt.Errorf("%w: expression expansion %%[...]", ErrUnsupportedSpec)
+		case c == '{':
+			end, nested := i+2, 1
+			for ; end < len(s) && nested > 0; end++ {
+				switch s[end] {
+				case '{':
+					nested++
+				case '}':
+					nested--
+				}
+			}
+			if nested > 0 {
+				return "", fmt.Errorf("unterminated macro in %q", s)
+			}
+			v, err := p.expandMacro(s[i+2:end-1], depth)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = end
+		case c == '?' || c == '!' && i+2 < len(s) && s[i+2] == '?':
+			end := i + 2
+			if c == '!' {
+				end++
+			}
+			for end < len(s) && (isAlpha(s[end]) || isDigit(s[end]) || s[end] == '_') {
+				end++
+			}
+			v, err := p.expandMacro(s[i+1:end], depth)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = end
+		case isAlpha(c) || c == '_':
+			end := i + 1
+			for end < len(s) && (isAlpha(s[end]) || isDigit(s[end]) || s[end] == '_') {
+				end++
+			}
+			name := s[i+1 : end]
+			if body, ok := p.macros[name]; ok {
+				v, err := p.expandDepth(body, depth+1)
+				if err != nil {
+					return "", err
+				}
+				b.WriteString(v)
+			} else {
+				b.WriteString(s[i:end])
+			}
+			i = end
+		default:
+			b.WriteByte('%')
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// expandMacro expands the body of `%{...}`: a name, `?name`, `!?name`, and
+// the conditional forms `?name:text` and `!?name:text`.
+func (p *specParser) expandMacro(m string, depth int) (string, error) {
+	var cond, neg bool
+	body := m
+	switch {
+	case strings.HasPrefix(body, "!?"):
+		cond, neg, body = true, true, body[2:]
+	case strings.HasPrefix(body, "?"):
+		cond, body = true, body[1:]
+	}
+	name, text, hasText := strings.Cut(body, ":")
+	if !isMacroName(name) || hasText && !cond {
+		return "", fmt.Errorf("%w: macro %%{%s}", ErrUnsupportedSpec, m)
+	}
+	value, defined := p.macros[name]
+	switch {
+	case !cond && !defined:
+		return "%{" + m + "}", nil
+	case !cond:
+		return p.expandDepth(value, depth+1)
+	case neg && !defined && hasText:
+		return p.expandDepth(text, depth+1)
+	case neg || !defined:
+		return "", nil
+	case hasText:
+		return p.expandDepth(text, depth+1)
+	}
+	return p.expandDepth(value, depth+1)
+}
+
+// parseLine reads a line of the spec file.
+func (p *specParser) parseLine(raw string) error {
+	if f := strings.Fields(raw); len(f) > 0 {
+		if reason, ok := unsupportedSpecSections[f[0]]; ok {
+			return fmt.Errorf("%w: %s, %s", ErrUnsupportedSpec, f[0], reason)
+		}
+	}
+	line, err := p.expand(raw)
+	if err != nil {
+		return err
+	}
+	if f := strings.Fields(line); len(f) > 0 && p.isSection(f[0]) {
+		if err := p.endSection(); err != nil {
+			return err
+		}
+		if p.seenSections[f[0]] && f[0] != "%files" {
+			return fmt.Errorf("second %s section", f[0])
+		}
+		p.seenSections[f[0]] = true
+		p.section, p.sectionArgs, p.sectionLine, p.body = f[0], f[1:], p.line, nil
+		return p.startSection()
+	}
+	switch p.section {
+	case "":
+		return p.preamble(line)
+	case "%files":
+		return p.filesLine(line)
+	}
+	p.body = append(p.body, line)
+	return nil
+}
+
+func (p *specParser) isSection(name string) bool {
+	_, script := specScriptlets[name]
+	return script || name == "%description" || name == "%files" || name == "%changelog"
+}
+
+// startSection checks the arguments of a section.
+func (p *specParser) startSection() error {
+	args := p.sectionArgs
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "-p" && specScriptlets[p.section] != "" && i+1 < len(args):
+			i++
+		case a == "-q" && specScriptlets[p.section] != "":
+		case a == "-f":
+			return fmt.Errorf("%w: %s -f, read the contents of the section from the spec file", ErrUnsupportedSpec, p.section)
+		default:
+			return fmt.Errorf("%w: %s %s, subpackages are not supported", ErrUnsupportedSpec, p.section, strings.Join(args, " "))
+		}
+	}
+	return nil
+}
+
+// sectionBody returns the body of the current section without leading and
+// trailing empty lines.
+func (p *specParser) sectionBody() string {
+	return strings.Trim(strings.Join(p.body, "\n"), "\n")
+}
+
+// endSection finishes the current section.
+func (p *specParser) endSection() error {
+	md := &p.spec.Metadata
+	switch p.section {
+	case "%description":
+		md.Description = p.sectionBody()
+	case "%changelog":
+		c, err := parseSpecChangelog(p.body)
+		if err != nil {
+			return err
+		}
+		md.Changelog = c
+	default:
+		name, ok := specScriptlets[p.section]
+		if !ok {
+			return nil
+		}
+		var interpreter []string
+		for i, a := range p.sectionArgs {
+			if a == "-p" {
+				interpreter = []string{p.sectionArgs[i+1]}
+			}
+		}
+		if s := NewScriptlet(p.sectionBody(), interpreter...); !s.empty() {
+			p.spec.scriptlets[name] = s
+		}
+	}
+	return nil
+}
+
+// preamble reads a `Tag: value` line of the preamble.
+func (p *specParser) preamble(line string) error {
+	if t := strings.TrimSpace(line); t == "" || strings.HasPrefix(t, "#") {
+		return nil
+	}
+	m := specTagRE.FindStringSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("invalid preamble line %q", line)
+	}
+	tag, value := strings.ToLower(m[1]), strings.TrimSpace(m[3])
+	md := &p.spec.Metadata
+	if _, ok := dependencyTags[tag]; ok {
+		return md.AddDependencies(line)
+	}
+	if m[2] != "" {
+		return fmt.Errorf("%s does not take qualifiers", m[1])
+	}
+	switch tag {
+	case "name":
+		md.Name = value
+	case "version":
+		md.Version = value
+	case "release":
+		md.Release = value
+	case "epoch":
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid epoch %q", value)
+		}
+		md.Epoch = uint32(n)
+	case "summary":
+		md.Summary = value
+	case "license":
+		md.Licence = value
+	case "url":
+		md.URL = value
+	case "group":
+		md.Group = value
+	case "vendor":
+		md.Vendor = value
+	case "packager":
+		md.Packager = value
+	case "buildarch", "buildarchitectures":
+		md.Arch = value
+	case "prefix", "prefixes":
+		md.Prefixes = append(md.Prefixes, strings.Fields(value)...)
+	default:
+		if specIgnoredTags[strings.TrimRight(tag, "0123456789")] {
+			return nil
+		}
+		return fmt.Errorf("%w: tag %s", ErrUnsupportedSpec, m[1])
+	}
+	// Only these tags are available as macros, e.g. %{version}.
+	// Others would clash with %files directives, e.g. License and %license.
+	switch tag {
+	case "name", "version", "release", "epoch":
+		p.macros[tag] = value
+	}
+	return nil
+}
+
+// parseSpecAttr parses the arguments of %attr or %defattr, where `-` keeps
+// the attribute.
+func parseSpecAttr(args string, defattr bool) (specAttr, error) {
+	a := specAttr{mode: -1, dirMode: -1}
+	fields := strings.Split(args, ",")
+	if len(fields) != 3 && !(defattr && len(fields) == 4) {
+		return a, fmt.Errorf("invalid attributes %q", args)
+	}
+	modes := []*int{&a.mode, &a.dirMode}
+	for i, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "-" {
+			continue
+		}
+		switch i {
+		case 0, 3:
+			mode, err := strconv.ParseUint(f, 8, 32)
+			if err != nil || mode&^07777 != 0 {
+				return a, fmt.Errorf("invalid mode %q", f)
+			}
+			*modes[i/3] = int(mode)
+		case 1:
+			a.owner = f
+		case 2:
+			a.group = f
+		}
+	}
+	// %attr sets the mode of directories too.
+	if !defattr {
+		a.dirMode = a.mode
+	}
+	return a, nil
+}
+
+// merge returns the attributes of o on top of a.
+func (a specAttr) merge(o specAttr) specAttr {
+	if o.mode >= 0 {
+		a.mode = o.mode
+	}
+	if o.dirMode >= 0 {
+		a.dirMode = o.dirMode
+	}
+	if o.owner != "" {
+		a.owner = o.owner
+	}
+	if o.group != "" {
+		a.group = o.group
+	}
+	return a
+}
+
+// filesLine reads a line of %files: directives like %attr(...) or %config
+// followed by the paths they apply to.
+func (p *specParser) filesLine(line string) error {
+	rest := strings.TrimSpace(line)
+	if rest == "" || strings.HasPrefix(rest, "#") {
+		return nil
+	}
+	f := specFile{line: p.line, attr: p.defattr}
+	var paths []string
+	for rest != "" {
+		if rest[0] != '%' {
+			tok, next, _ := strings.Cut(rest, " ")
+			if i := strings.IndexByte(tok, '\t'); i >= 0 {
+				tok, next = tok[:i], tok[i+1:]+" "+next
+			}
+			paths = append(paths, strings.Trim(tok, `"`))
+			rest = strings.TrimSpace(next)
+			continue
+		}
+		end := 1
+		for end < len(rest) && (isAlpha(rest[end]) || rest[end] == '_') {
+			end++
+		}
+		directive, arg, hasArg := rest[:end], "", false
+		if end < len(rest) && rest[end] == '(' {
+			n := strings.IndexByte(rest[end:], ')')
+			if n < 0 {
+				return fmt.Errorf("unterminated %s", directive)
+			}
+			arg, hasArg = rest[end+1:end+n], true
+			end += n + 1
+		}
+		rest = strings.TrimSpace(rest[end:])
+
+		switch directive {
+		case "%defattr":
+			a, err := parseSpecAttr(arg, true)
+			if err != nil {
+				return err
+			}
+			p.defattr = a
+			return nil
+		case "%attr":
+			a, err := parseSpecAttr(arg, false)
+			if err != nil {
+				return err
+			}
+			f.attr = f.attr.merge(a)
+		case "%config":
+			f.fileType |= ConfigFile
+			for _, o := range strings.FieldsFunc(arg, func(r rune) bool { return r == ',' || r == ' ' }) {
+				switch o {
+				case "noreplace":
+					f.fileType |= NoReplaceFile
+				case "missingok":
+					f.fileType |= MissingOkFile
+				default:
+					return fmt.Errorf("invalid %%config option %q", o)
+				}
+			}
+		case "%doc":
+			f.fileType |= DocFile
+		case "%license":
+			f.fileType |= LicenceFile
+		case "%readme":
+			f.fileType |= ReadmeFile
+		case "%ghost":
+			f.fileType |= GhostFile
+		case "%dir":
+			f.dir = true
+		case "%exclude":
+			f.exclude = true
+		case "%verify":
+			v, err := ParseNoVerify(arg)
+			if err != nil {
+				return err
+			}
+			f.noVerify = &v
+		case "%lang":
+			f.lang = arg
+		case "%caps":
+			f.caps = arg
+		default:
+			return fmt.Errorf("%w: %s in %%files", ErrUnsupportedSpec, directive)
+		}
+		if hasArg && arg == "" {
+			return fmt.Errorf("%s without arguments", directive)
+		}
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no file in %q", line)
+	}
+	for _, name := range paths {
+		if !strings.HasPrefix(name, "/") {
+			if f.fileType&(DocFile|LicenceFile) != 0 {
+				return fmt.Errorf("%w: relative %%doc or %%license %q, which is copied from the build directory; install it into the buildroot and list its absolute path", ErrUnsupportedSpec, name)
+			}
+			return fmt.Errorf("file %q must begin with /", name)
+		}
+		f.path = path.Clean(name)
+		p.spec.files = append(p.spec.files, f)
+	}
+	return nil
+}
+
+// parseSpecChangelog reads the entries of %changelog, each starting with a
+// line like `* Tue Jan 02 2024 Jane Doe <jane@example.com> - 1.2-3`.
+func parseSpecChangelog(lines []string) (Changelog, error) {
+	var (
+		c    Changelog
+		text []string
+	)
+	flush := func() {
+		if len(c) > 0 {
+			c[len(c)-1].Text = strings.Trim(strings.Join(text, "\n"), "\n")
+		}
+		text = nil
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "*") {
+			if len(c) == 0 && strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("changelog text %q before the first entry", line)
+			}
+			text = append(text, line)
+			continue
+		}
+		flush()
+		f := strings.Fields(line[1:])
+		if len(f) < 5 {
+			return nil, fmt.Errorf("invalid changelog entry %q", line)
+		}
+		t, err := time.Parse("Mon Jan 2 2006", strings.Join(f[:4], " "))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date in changelog entry %q", line)
+		}
+		// Like rpm, the entry is at noon, though in UTC to be reproducible.
+		c = append(c, &ChangelogEntry{
+			Time: uint32(t.Add(12 * time.Hour).Unix()),
+			Name: strings.Join(f[4:], " "),
+		})
+	}
+	flush()
+	return c, nil
+}
+
+// FromTar creates the package with the files of %files, taken from a tar
+// file of the buildroot. Like FromTar, file bodies are spooled rather than
+// kept in memory.
+func (s *Spec) FromTar(inp io.Reader) (*RPM, error) {
+	r, err := NewRPM(s.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RPM structure: %w", err)
+	}
+	r.bodies = newSpool(spoolMemLimit)
+	found := map[string]RPMFile{}
+	if err := readTar(inp, r.bodies, func(f RPMFile) error {
+		found[f.Name] = f
+		return nil
+	}); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if err := s.apply(r, found); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// FromDir creates the package with the files of %files, taken from the
+// buildroot directory root. Files are owned by root unless %attr or
+// %defattr say otherwise, and are read when the package is written.
+func (s *Spec) FromDir(root string) (*RPM, error) {
+	found := map[string]RPMFile{}
+	if err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f := RPMFile{
+			Name:  path.Join("/", filepath.ToSlash(rel)),
+			Mode:  uint(info.Mode().Perm()),
+			Owner: "root",
+			Group: "root",
+			MTime: uint32(info.ModTime().Unix()),
+		}
+		for _, bit := range []struct {
+			m    fs.FileMode
+			mode uint
+		}{{fs.ModeSetuid, 04000}, {fs.ModeSetgid, 02000}, {fs.ModeSticky, 01000}} {
+			if info.Mode()&bit.m != 0 {
+				f.Mode |= bit.mode
+			}
+		}
+		switch t := info.Mode().Type(); {
+		case t == fs.ModeDir:
+			f.Mode |= modeDir
+		case t == fs.ModeSymlink:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			f.Mode |= modeSymlink
+			f.Body = []byte(target)
+		case t.IsRegular():
+			f.Mode |= modeRegular
+			f.Size = info.Size()
+			f.Source = func() (io.ReadCloser, error) { return os.Open(p) }
+		default:
+			return fmt.Errorf("unsupported file type of %q", p)
+		}
+		found[f.Name] = f
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to read buildroot: %w", err)
+	}
+	r, err := NewRPM(s.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RPM structure: %w", err)
+	}
+	if err := s.apply(r, found); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// apply adds the scriptlets and the files of %files, found in the buildroot,
+// to the package.
+func (s *Spec) apply(r *RPM, found map[string]RPMFile) error {
+	for name, sc := range s.scriptlets {
+		scriptletAdders[name](r, sc.Body, sc.Interpreter...)
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		if name != "/" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	packaged := map[string]RPMFile{}
+	excluded := map[string]bool{}
+	for _, sf := range s.files {
+		var matched []string
+		for _, name := range names {
+			if ok, _ := path.Match(sf.path, name); ok {
+				matched = append(matched, name)
+				// Directories come with their content unless listed with %dir.
+				if found[name].Mode&modeTypeMask == modeDir && !sf.dir {
+					for _, below := range names {
+						if strings.HasPrefix(below, name+"/") {
+							matched = append(matched, below)
+						}
+					}
+				}
+			}
+		}
+		if len(matched) == 0 {
+			if sf.fileType&GhostFile == 0 || sf.exclude {
+				return &SpecError{Line: sf.line, Err: fmt.Errorf("%w: %s", ErrSpecFileNotFound, sf.path)}
+			}
+			// Ghost files need not be in the buildroot.
+			mode := uint(modeRegular | 0644)
+			if sf.dir {
+				mode = modeDir | 0755
+			}
+			packaged[sf.path] = sf.applyTo(RPMFile{Name: sf.path, Mode: mode, Owner: "root", Group: "root"})
+			continue
+		}
+		for _, name := range matched {
+			if sf.exclude {
+				excluded[name] = true
+				delete(packaged, name)
+				continue
+			}
+			packaged[name] = sf.applyTo(found[name])
+		}
+	}
+
+	var unpackaged []string
+	for _, name := range names {
+		if _, ok := packaged[name]; !ok && !excluded[name] && found[name].Mode&modeTypeMask != modeDir {
+			unpackaged = append(unpackaged, name)
+		}
+	}
+	if len(unpackaged) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnpackagedFiles, strings.Join(unpackaged, ", "))
+	}
+
+	for _, name := range names {
+		if f, ok := packaged[name]; ok {
+			r.AddFile(f)
+			delete(packaged, name)
+		}
+	}
+	// What is left are ghosts missing from the buildroot.
+	ghosts := make([]string, 0, len(packaged))
+	for name := range packaged {
+		ghosts = append(ghosts, name)
+	}
+	sort.Strings(ghosts)
+	for _, name := range ghosts {
+		r.AddFile(packaged[name])
+	}
+	return nil
+}
+
+// applyTo sets the attributes of the %files line on the file.
+func (sf specFile) applyTo(f RPMFile) RPMFile {
+	mode := sf.attr.mode
+	switch f.Mode & modeTypeMask {
+	case modeDir:
+		mode = sf.attr.dirMode
+	case modeSymlink:
+		// The mode of symlinks is always 0777.
+		mode = -1
+	}
+	if mode >= 0 {
+		f.Mode = f.Mode&^07777 | uint(mode)
+	}
+	if sf.attr.owner != "" {
+		f.Owner = sf.attr.owner
+	}
+	if sf.attr.group != "" {
+		f.Group = sf.attr.group
+	}
+	f.Type |= sf.fileType
+	if sf.noVerify != nil {
+		f.NoVerify = *sf.noVerify
+	}
+	if sf.lang != "" {
+		f.Lang = sf.lang
+	}
+	if sf.caps != "" {
+		f.Capabilities = sf.caps
+	}
+	return f
+}
@@ -0,0 +1,345 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testSpec = `# A test package.
+%global upstream_version 1.2
+%define shortname foo
+
+Name:           %{shortname}
+Version:        %{upstream_version}
+Release:        3%{?dist}
+Epoch:          1
+Summary:        The %{name} tool
+License:        MIT
+URL:            https://example.com/%{name}
+Source0:        %{name}-%{version}.tar.gz
+BuildRequires:  gcc
+BuildArch:      x86_64
+Requires:       bash >= 5, coreutils
+Requires(post): systemd
+Provides:       %{name}-tool = %{version}
+
+%description
+Foo does things.
+
+It does them well, 100%%.
+
+%pre -p <lua>
+print("hello")
+
+%post
+systemctl daemon-reload
+
+%postun -p /sbin/ldconfig
+
+%files
+%defattr(-,root,root,0755)
+%{_bindir}/%{name}
+%dir %{_sysconfdir}/%{name}
+%config(noreplace) %attr(0640,root,%{name}) %{_sysconfdir}/%{name}/*.conf
+%doc %{_docdir}/%{name}
+%license %{_datadir}/licenses/%{name}/COPYING
+%ghost %attr(0644,%{name},%{name}) /var/log/%{name}.log
+%exclude %{_docdir}/%{name}/TODO
+
+%changelog
+* Tue Jan 02 2024 Jane Doe <jane@example.com> - 1:1.2-3
+- Release 1.2
+
+* Mon Jan  1 2024 Jane Doe <jane@example.com> - 1:1.1-1
+- Release 1.1
+- Fix things
+`
+
+func TestParseSpec(t *testing.T) {
+	s, err := ParseSpec(strings.NewReader(testSpec), map[string]string{"dist": ".el9"})
+	if err != nil {
+		t.Fatalf("ParseSpec returned err: %v", err)
+	}
+	want := RPMMetaData{
+		Name:        "foo",
+		Version:     "1.2",
+		Release:     "3.el9",
+		Epoch:       1,
+		Summary:     "The foo tool",
+		Licence:     "MIT",
+		URL:         "https://example.com/foo",
+		Arch:        "x86_64",
+		Description: "Foo does things.\n\nIt does them well, 100%.",
+		Requires: Relations{
+			{Name: "bash", Version: "5", Sense: SenseGreater | SenseEqual},
+			{Name: "coreutils"},
+			{Name: "systemd", Sense: ScriptPost},
+		},
+		Provides: Relations{{Name: "foo-tool", Version: "1.2", Sense: SenseEqual}},
+		Changelog: Changelog{
+			{Time: 1704196800, Name: "Jane Doe <jane@example.com> - 1:1.2-3", Text: "- Release 1.2"},
+			{Time: 1704110400, Name: "Jane Doe <jane@example.com> - 1:1.1-1", Text: "- Release 1.1\n- Fix things"},
+		},
+	}
+	if d := cmp.Diff(want, s.Metadata); d != "" {
+		t.Errorf("Metadata differs (want->got):\n%v", d)
+	}
+	wantScriptlets := map[string]Scriptlet{
+		"prein":  NewScriptlet(`print("hello")`, LuaInterpreter),
+		"postin": NewScriptlet("systemctl daemon-reload"),
+		"postun": NewScriptlet("", "/sbin/ldconfig"),
+	}
+	if d := cmp.Diff(wantScriptlets, s.scriptlets); d != "" {
+		t.Errorf("scriptlets differ (want->got):\n%v", d)
+	}
+}
+
+func TestSpecMacros(t *testing.T) {
+	p := &specParser{macros: map[string]string{
+		"name":  "foo",
+		"empty": "%{nil}",
+		"nil":   "",
+		"loop":  "%{loop}",
+	}}
+	testCases := []struct {
+		input, want string
+	}{
+		{"%name-%{name}", "foo-foo"},
+		{"%{?name}|%{?dist}|%?name", "foo||foo"},
+		{"%{?name:set}|%{?dist:set}", "set|"},
+		{"%{!?name:unset}|%{!?dist:unset}|%{!?name}", "|unset|"},
+		{"%{undefined} %undefined", "%{undefined} %undefined"},
+		{"%{empty}x", "x"},
+		{"100%% %", "100% %"},
+		{"%{?dist:%{name}}%{!?dist:%{name}.el9}", "foo.el9"},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := p.expand(tc.input)
+			if err != nil {
+				t.Fatalf("expand(%q) returned err: %v", tc.input, err)
+			}
+			if got != tc.want {
+				t.Errorf("expand(%q) want %q, got %q", tc.input, tc.want, got)
+			}
+		})
+	}
+	for _, input := range []string{"%{loop}", "%(date)", "%{lua:print(1)}", "%{name"} {
+		if _, err := p.expand(input); err == nil {
+			t.Errorf("expand(%q) returned no error", input)
+		}
+	}
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	const preamble = "Name: foo\nVersion: 1\n"
+	testCases := []struct {
+		name, spec      string
+		wantUnsupported bool
+	}{
+		{"build", preamble + "%build\nmake\n", true},
+		{"install", preamble + "%install\nmake install\n", true},
+		{"package", preamble + "%package devel\nSummary: x\n", true},
+		{"subpackage files", preamble + "%files devel\n/usr/include\n", true},
+		{"conditional", preamble + "%if 0%{?rhel}\nRequires: foo\n%endif\n", true},
+		{"trigger", preamble + "%triggerin -- bar\ntrue\n", true},
+		{"shell", preamble + "Release: %(date +%%s)\n", true},
+		{"parametric", "%define foo() bar\n" + preamble, true},
+		{"tag", preamble + "Distribution: foo\n", true},
+		{"relative doc", preamble + "%files\n%doc README\n", true},
+		{"files directive", preamble + "%files\n%artifact /usr/lib/foo\n", true},
+		{"no version", "Name: foo\n", false},
+		{"bad preamble", preamble + "what is this\n", false},
+		{"bad epoch", preamble + "Epoch: x\n", false},
+		{"bad attr", preamble + "%files\n%attr(999,root,root) /foo\n", false},
+		{"bad changelog", preamble + "%changelog\n* yesterday me\n", false},
+		{"second description", preamble + "%description\nx\n%description\ny\n", false},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseSpec(strings.NewReader(tc.spec), nil)
+			if err == nil {
+				t.Fatal("ParseSpec returned no error")
+			}
+			if got := errors.Is(err, ErrUnsupportedSpec); got != tc.wantUnsupported {
+				t.Errorf("ParseSpec returned %v, want ErrUnsupportedSpec: %v", err, tc.wantUnsupported)
+			}
+		})
+	}
+}
+
+type testSpecFile struct {
+	name, body string
+	dir        bool
+}
+
+var testBuildroot = []testSpecFile{
+	{name: "usr/bin/foo", body: "binary"},
+	{name: "etc/foo", dir: true},
+	{name: "etc/foo/foo.conf", body: "conf"},
+	{name: "usr/share/doc/foo", dir: true},
+	{name: "usr/share/doc/foo/README", body: "readme"},
+	{name: "usr/share/doc/foo/TODO", body: "todo"},
+	{name: "usr/share/licenses/foo/COPYING", body: "license"},
+}
+
+// testSpecFiles returns the files of the test spec, as read back from the
+// package.
+func testSpecFiles(t *testing.T, r *RPM) map[string]RPMFile {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "spec.rpm")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer f.Close()
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned err: %v", err)
+	}
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned err: %v", err)
+	}
+	return got.files
+}
+
+func TestSpecFiles(t *testing.T) {
+	s, err := ParseSpec(strings.NewReader(testSpec), nil)
+	if err != nil {
+		t.Fatalf("ParseSpec returned err: %v", err)
+	}
+
+	dir := t.TempDir()
+	for _, f := range testBuildroot {
+		name := filepath.Join(dir, f.name)
+		if f.dir {
+			if err := os.MkdirAll(name, 0700); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(name, []byte(f.body), 0600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	b := &bytes.Buffer{}
+	ta := tar.NewWriter(b)
+	for _, f := range testBuildroot {
+		h := &tar.Header{Name: f.name, Mode: 0600, Size: int64(len(f.body)), Uname: "builder", Gname: "builder"}
+		if f.dir {
+			h.Typeflag, h.Mode, h.Size = tar.TypeDir, 0700, 0
+		}
+		if err := ta.WriteHeader(h); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if _, err := ta.Write([]byte(f.body)); err != nil {
+			t.Fatalf("failed to write body: %v", err)
+		}
+	}
+	ta.Close()
+
+	type attrs struct {
+		Mode         uint
+		Owner, Group string
+		Type         FileType
+	}
+	want := map[string]attrs{
+		"/usr/bin/foo":              {0100600, "root", "root", 0},
+		"/etc/foo":                  {040755, "root", "root", 0},
+		"/etc/foo/foo.conf":         {0100640, "root", "foo", ConfigFile | NoReplaceFile},
+		"/usr/share/doc/foo":        {040755, "root", "root", DocFile},
+		"/usr/share/doc/foo/README": {0100600, "root", "root", DocFile},
+		// License is not a macro clashing with %license.
+		"/usr/share/licenses/foo/COPYING": {0100600, "root", "root", LicenceFile},
+	}
+	for _, tc := range []struct {
+		name string
+		rpm  func() (*RPM, error)
+	}{
+		{"dir", func() (*RPM, error) { return s.FromDir(dir) }},
+		{"tar", func() (*RPM, error) { return s.FromTar(b) }},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := tc.rpm()
+			if err != nil {
+				t.Fatalf("creating the package returned err: %v", err)
+			}
+			// Ghost files are not in the payload, so they are not read back.
+			g := r.files["/var/log/foo.log"]
+			if d := cmp.Diff(attrs{0100644, "foo", "foo", GhostFile}, attrs{g.Mode, g.Owner, g.Group, g.Type}); d != "" {
+				t.Errorf("ghost file differs (want->got):\n%v", d)
+			}
+			if d := cmp.Diff(NewScriptlet("systemctl daemon-reload"), r.postin); d != "" {
+				t.Errorf("postin differs (want->got):\n%v", d)
+			}
+			files := map[string]attrs{}
+			for name, f := range testSpecFiles(t, r) {
+				files[name] = attrs{f.Mode, f.Owner, f.Group, f.Type}
+			}
+			if d := cmp.Diff(want, files); d != "" {
+				t.Errorf("files differ (want->got):\n%v", d)
+			}
+		})
+	}
+}
+
+func TestSpecFilesErrors(t *testing.T) {
+	const preamble = "Name: foo\nVersion: 1\n%files\n"
+	testCases := []struct {
+		files string
+		want  error
+	}{
+		{"/usr/bin/foo\n/usr/bin/bar\n", ErrSpecFileNotFound},
+		{"/usr/bin/*\n", ErrUnpackagedFiles},
+		{"/usr/bin/foo\n%exclude /usr/bin/baz\n", ErrSpecFileNotFound},
+		{"/usr/bin/foo\n%ghost /usr/bin/bar\n", ErrUnpackagedFiles},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.files, func(t *testing.T) {
+			s, err := ParseSpec(strings.NewReader(preamble+tc.files), nil)
+			if err != nil {
+				t.Fatalf("ParseSpec returned err: %v", err)
+			}
+			dir := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(dir, "usr/bin"), 0755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "usr/bin/foo"), nil, 0755); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "etc"), nil, 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if _, err := s.FromDir(dir); !errors.Is(err, tc.want) {
+				t.Errorf("FromDir returned %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
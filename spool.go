@@ -0,0 +1,125 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// spoolMemLimit is the amount of data a spool keeps in memory before it moves
+// its content to a temporary file.
+const spoolMemLimit = 32 << 20
+
+// spool is an append-only buffer. Small content is kept in memory, and once it
+// grows past its limit it is moved to a temporary file. It is used for the
+// compressed payload and for file bodies read from a tar stream, so the memory
+// used while building an rpm stays bounded no matter how big the package is.
+type spool struct {
+	mem   bytes.Buffer
+	f     *os.File
+	size  int64
+	limit int64
+}
+
+func newSpool(limit int64) *spool {
+	return &spool{limit: limit}
+}
+
+// Write appends p to the spool, spilling to a temporary file if needed.
+func (s *spool) Write(p []byte) (int, error) {
+	if s.f == nil && int64(s.mem.Len()+len(p)) > s.limit {
+		if err := s.spill(); err != nil {
+			return 0, err
+		}
+	}
+	var (
+		n   int
+		err error
+	)
+	if s.f != nil {
+		n, err = s.f.Write(p)
+	} else {
+		n, err = s.mem.Write(p)
+	}
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *spool) spill() error {
+	f, err := os.CreateTemp("", "rpmpack-*")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	if _, err := f.Write(s.mem.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	s.mem = bytes.Buffer{}
+	s.f = f
+	return nil
+}
+
+// ReadAt implements io.ReaderAt over everything written so far.
+func (s *spool) ReadAt(p []byte, off int64) (int, error) {
+	if s.f != nil {
+		return s.f.ReadAt(p, off)
+	}
+	return bytes.NewReader(s.mem.Bytes()).ReadAt(p, off)
+}
+
+// Len returns the number of bytes written to the spool.
+func (s *spool) Len() int64 {
+	return s.size
+}
+
+// Reader returns a reader for the whole content of the spool.
+func (s *spool) Reader() io.Reader {
+	return io.NewSectionReader(s, 0, s.size)
+}
+
+// opener returns a function opening n bytes of the spool starting at off,
+// suitable for RPMFile.Source.
+func (s *spool) opener(off, n int64) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return sectionReadCloser{io.NewSectionReader(s, off, n)}, nil
+	}
+}
+
+// sectionReadCloser keeps io.ReaderAt of a section visible to readers of
+// RPMFile.Source.
+type sectionReadCloser struct {
+	*io.SectionReader
+}
+
+func (sectionReadCloser) Close() error { return nil }
+
+// Close removes the temporary file, if any. Content which was only kept in
+// memory stays readable.
+func (s *spool) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	name := s.f.Name()
+	err := s.f.Close()
+	s.f = nil
+	if rerr := os.Remove(name); err == nil {
+		err = rerr
+	}
+	return err
+}
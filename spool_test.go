@@ -0,0 +1,91 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSpool(t *testing.T) {
+	testCases := []struct {
+		name      string
+		limit     int64
+		writes    []string
+		wantSpill bool
+	}{{
+		name:   "in memory",
+		limit:  100,
+		writes: []string{"hello ", "world"},
+	}, {
+		name:      "spilled",
+		limit:     8,
+		writes:    []string{"hello ", "world"},
+		wantSpill: true,
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSpool(tc.limit)
+			defer s.Close()
+			for _, w := range tc.writes {
+				if _, err := io.WriteString(s, w); err != nil {
+					t.Fatalf("Write returned error %v", err)
+				}
+			}
+			if got := s.f != nil; got != tc.wantSpill {
+				t.Errorf("spilled = %v, want %v", got, tc.wantSpill)
+			}
+			if got := s.Len(); got != 11 {
+				t.Errorf("Len() = %d, want 11", got)
+			}
+			all, err := io.ReadAll(s.Reader())
+			if err != nil {
+				t.Fatalf("ReadAll returned error %v", err)
+			}
+			if d := cmp.Diff("hello world", string(all)); d != "" {
+				t.Errorf("spool content differs (want->got):\n%v", d)
+			}
+			rc, err := s.opener(6, 5)()
+			if err != nil {
+				t.Fatalf("opener returned error %v", err)
+			}
+			part, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("ReadAll returned error %v", err)
+			}
+			if d := cmp.Diff("world", string(part)); d != "" {
+				t.Errorf("spool section differs (want->got):\n%v", d)
+			}
+		})
+	}
+}
+
+func TestSpoolCloseRemovesFile(t *testing.T) {
+	s := newSpool(1)
+	if _, err := io.WriteString(s, "spilled"); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	name := s.f.Name()
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned error %v", err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("spool file %s still exists after Close", name)
+	}
+}
@@ -19,14 +19,20 @@ package rpmpack
 const (
 	tagHeaderI18NTable = 0x64 // 100
 	// Signature tags are obiously overlapping regular header tags..
-	sigRSA         = 0x010c // 256
-	sigSHA256      = 0x0111 // 273
-	sigSize        = 0x03e8 // 1000
-	sigPGP         = 0x03ea // 1002
-	sigPayloadSize = 0x03ef // 1007
+	sigRSA             = 0x010c // 256
+	sigLongSize        = 0x010e // 270
+	sigLongArchiveSize = 0x010f // 271
+	sigSHA256          = 0x0111 // 273
+	sigSize            = 0x03e8 // 1000
+	sigPGP             = 0x03ea // 1002
+	sigPayloadSize     = 0x03ef // 1007
 
 	// https://github.com/rpm-software-management/rpm/blob/92eadae94c48928bca90693ad63c46ceda37d81f/rpmio/rpmpgp.h#L258
+	hashAlgoMD5    = 0x0001 // 1
+	hashAlgoSHA1   = 0x0002 // 2
 	hashAlgoSHA256 = 0x0008 // 8
+	hashAlgoSHA384 = 0x0009 // 9
+	hashAlgoSHA512 = 0x000a // 10
 
 	tagName        = 0x03e8 // 1000
 	tagVersion     = 0x03e9 // 1001
@@ -41,6 +47,8 @@ const (
 	tagLicence     = 0x03f6 // 1014
 	tagPackager    = 0x03f7 // 1015
 	tagGroup       = 0x03f8 // 1016
+	tagSource      = 0x03fa // 1018
+	tagPatch       = 0x03fb // 1019
 	tagURL         = 0x03fc // 1020
 	tagOS          = 0x03fd // 1021
 	tagArch        = 0x03fe // 1022
@@ -68,6 +76,12 @@ const (
 	tagConflictFlags     = 0x041d // 1053
 	tagConflicts         = 0x041e // 1054
 	tagConflictVersion   = 0x041f // 1055
+	tagTriggerScripts    = 0x0429 // 1065
+	tagTriggerName       = 0x042a // 1066
+	tagTriggerVersion    = 0x042b // 1067
+	tagTriggerFlags      = 0x042c // 1068
+	tagTriggerIndex      = 0x042d // 1069
+	tagVerifyScript      = 0x0437 // 1079
 	tagChangelogTime     = 0x0438 // 1080	int32 array	Per entry changelog Unix timestamp.
 	tagChangelogName     = 0x0439 // 1081	string array	Per entry changelog author information, typically name <email>.
 	tagChangelogText     = 0x043a // 1082	string array	Per entry changelog text.
@@ -76,9 +90,13 @@ const (
 	tagPreunProg         = 0x043f // 1087
 	tagPostunProg        = 0x0440 // 1088
 	tagObsoletes         = 0x0442 // 1090
+	tagVerifyScriptProg  = 0x0443 // 1091
+	tagTriggerScriptProg = 0x0444 // 1092
+	tagFileDevices       = 0x0447 // 1095
 	tagFileINodes        = 0x0448 // 1096
 	tagFileLangs         = 0x0449 // 1097
 	tagPrefixes          = 0x044a // 1098
+	tagSourcePackage     = 0x0452 // 1106
 	tagProvideFlags      = 0x0458 // 1112
 	tagProvideVersion    = 0x0459 // 1113
 	tagObsoleteFlags     = 0x045a // 1114
@@ -93,6 +111,9 @@ const (
 	tagPosttrans         = 0x0480 // 1152
 	tagPretransProg      = 0x0481 // 1153
 	tagPosttransProg     = 0x0482 // 1154
+	tagLongFileSizes     = 0x1390 // 5008
+	tagLongSize          = 0x1391 // 5009
+	tagFileCaps          = 0x1392 // 5010
 	tagFileDigestAlgo    = 0x1393 // 5011
 	tagRecommends        = 0x13b6 // 5046
 	tagRecommendVersion  = 0x13b7 // 5047
@@ -100,6 +121,32 @@ const (
 	tagSuggests          = 0x13b9 // 5049
 	tagSuggestVersion    = 0x13ba // 5050
 	tagSuggestFlags      = 0x13bb // 5051
+
+	tagOrderName         = 0x13ab // 5035
+	tagOrderVersion      = 0x13ac // 5036
+	tagOrderFlags        = 0x13ad // 5037
+	tagSupplements       = 0x13bc // 5052
+	tagSupplementVersion = 0x13bd // 5053
+	tagSupplementFlags   = 0x13be // 5054
+	tagEnhances          = 0x13bf // 5055
+	tagEnhanceVersion    = 0x13c0 // 5056
+	tagEnhanceFlags      = 0x13c1 // 5057
+
+	tagFileTriggerScripts         = 0x13ca // 5066
+	tagFileTriggerScriptProg      = 0x13cb // 5067
+	tagFileTriggerName            = 0x13cd // 5069
+	tagFileTriggerIndex           = 0x13ce // 5070
+	tagFileTriggerVersion         = 0x13cf // 5071
+	tagFileTriggerFlags           = 0x13d0 // 5072
+	tagTransFileTriggerScripts    = 0x13d1 // 5073
+	tagTransFileTriggerScriptProg = 0x13d2 // 5074
+	tagTransFileTriggerName       = 0x13d4 // 5076
+	tagTransFileTriggerIndex      = 0x13d5 // 5077
+	tagTransFileTriggerVersion    = 0x13d6 // 5078
+	tagTransFileTriggerFlags      = 0x13d7 // 5079
+	tagFileTriggerPriorities      = 0x13dc // 5084
+	tagTransFileTriggerPriorities = 0x13dd // 5085
+
 	tagPayloadDigest     = 0x13e4 // 5092
 	tagPayloadDigestAlgo = 0x13e5 // 5093
 )
@@ -18,43 +18,80 @@ import (
 	"archive/tar"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"path"
 )
 
 // FromTar reads a tar file and creates an rpm stuct.
+// File bodies are not kept in memory; larger contents are spooled to a
+// temporary file which is removed when the rpm is written, or by Close.
 func FromTar(inp io.Reader, md RPMMetaData) (*RPM, error) {
 
 	r, err := NewRPM(md)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create RPM structure: %w", err)
 	}
+	r.bodies = newSpool(spoolMemLimit)
+	if err := readTar(inp, r.bodies, func(f RPMFile) error {
+		r.AddFile(f)
+		return nil
+	}); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// readTar calls add with every entry of a tar file. The content of regular
+// files is spooled to bodies.
+func readTar(inp io.Reader, bodies *spool, add func(RPMFile) error) error {
 	t := tar.NewReader(inp)
 	for {
 		h, err := t.Next()
 		if err == io.EOF {
-			return r, nil
+			return nil
 		} else if err != nil {
-			return nil, fmt.Errorf("failed to read tar file: %w", err)
+			return fmt.Errorf("failed to read tar file: %w", err)
 		}
-		var body []byte
+		var (
+			body     []byte
+			source   func() (io.ReadCloser, error)
+			size     int64
+			hardLink string
+		)
 		switch h.Typeflag {
 		case tar.TypeDir:
 			h.Mode |= 040000
 		case tar.TypeSymlink:
 			body = []byte(h.Linkname)
 			h.Mode |= 0120000
+		case tar.TypeChar:
+			h.Mode |= modeChar
+		case tar.TypeBlock:
+			h.Mode |= modeBlock
+		case tar.TypeFifo:
+			h.Mode |= modeFIFO
+		case tar.TypeLink:
+			hardLink = path.Join("/", h.Linkname)
 		case tar.TypeReg:
-			b, err := ioutil.ReadAll(t)
+			off := bodies.Len()
+			n, err := io.Copy(bodies, t)
 			if err != nil {
-				return nil, fmt.Errorf("failed to read file (%q): %w", h.Name, err)
+				return fmt.Errorf("failed to read file (%q): %w", h.Name, err)
 			}
-			body = b
+			source = bodies.opener(off, n)
+			size = n
 		default:
-			return nil, fmt.Errorf("unknown tar type: %d, (%q)", h.Typeflag, h.Name)
+			return fmt.Errorf("unknown tar type: %d, (%q)", h.Typeflag, h.Name)
 		}
 		mtime := uint32(h.ModTime.Unix())
 
+		var caps string
+		if x, ok := h.PAXRecords[capsXattr]; ok {
+			if caps, err = capsFromXattr([]byte(x)); err != nil {
+				return fmt.Errorf("failed to read capabilities of %q: %w", h.Name, err)
+			}
+		}
+
 		// Sometimes the tar has no uname and gname. RPM expects these to always exist.
 		owner := h.Uname
 		if owner == "" {
@@ -65,14 +102,22 @@ func FromTar(inp io.Reader, md RPMMetaData) (*RPM, error) {
 			group = "root"
 		}
 
-		r.AddFile(
+		if err := add(
 			RPMFile{
-				Name:  path.Join("/", h.Name),
-				Body:  body,
-				Mode:  uint(h.Mode),
-				Owner: owner,
-				Group: group,
-				MTime: mtime,
-			})
+				Name:         path.Join("/", h.Name),
+				Body:         body,
+				Mode:         uint(h.Mode),
+				Owner:        owner,
+				Group:        group,
+				MTime:        mtime,
+				Source:       source,
+				Size:         size,
+				HardLink:     hardLink,
+				DevMajor:     uint32(h.Devmajor),
+				DevMinor:     uint32(h.Devminor),
+				Capabilities: caps,
+			}); err != nil {
+			return err
+		}
 	}
 }
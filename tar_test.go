@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -73,11 +74,13 @@ func TestFromTar(t *testing.T) {
 		input         io.Reader
 		wantBasenames []string
 		wantFileModes []uint16
+		wantSizes     []uint64
 	}{{
 		name:          "simple tar",
 		input:         createTar(t),
 		wantBasenames: []string{"dir1", "symlink1", "testfile1.txt"},
 		wantFileModes: []uint16{040755, 0120000, 0100644},
+		wantSizes:     []uint64{4096, 12, 8},
 	}}
 	for _, tc := range testCases {
 		tc := tc
@@ -98,6 +101,131 @@ func TestFromTar(t *testing.T) {
 			if d := cmp.Diff(tc.wantFileModes, r.filemodes); d != "" {
 				t.Errorf("FromTar filemodes differs (want->got):\n%v", d)
 			}
+			if d := cmp.Diff(tc.wantSizes, r.filesizes); d != "" {
+				t.Errorf("FromTar filesizes differs (want->got):\n%v", d)
+			}
 		})
 	}
 }
+
+func TestFromTarHardLink(t *testing.T) {
+	b := &bytes.Buffer{}
+	ta := tar.NewWriter(b)
+	if err := ta.WriteHeader(&tar.Header{Name: "bin/tool", Mode: 0755, Size: 4}); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	if _, err := ta.Write([]byte("tool")); err != nil {
+		t.Fatalf("failed to write body: %v", err)
+	}
+	if err := ta.WriteHeader(&tar.Header{Typeflag: tar.TypeLink, Name: "bin/alias", Linkname: "bin/tool", Mode: 0755}); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	ta.Close()
+
+	r, err := FromTar(b, RPMMetaData{})
+	if err != nil {
+		t.Fatalf("FromTar returned err: %v", err)
+	}
+	if got := r.files["/bin/alias"].HardLink; got != "/bin/tool" {
+		t.Errorf("FromTar hard link want /bin/tool, got %q", got)
+	}
+	if err := r.Write(ioutil.Discard); err != nil {
+		t.Fatalf("r.Write() returned err: %v", err)
+	}
+	if d := cmp.Diff([]int32{1, 1}, r.fileinodes); d != "" {
+		t.Errorf("FromTar inodes differs (want->got):\n%v", d)
+	}
+	if d := cmp.Diff([]uint64{4, 4}, r.filesizes); d != "" {
+		t.Errorf("FromTar filesizes differs (want->got):\n%v", d)
+	}
+}
+
+func TestFromTarDevices(t *testing.T) {
+	b := &bytes.Buffer{}
+	ta := tar.NewWriter(b)
+	for _, h := range []*tar.Header{
+		{Typeflag: tar.TypeChar, Name: "dev/tty", Mode: 0620, Devmajor: 5},
+		{Typeflag: tar.TypeBlock, Name: "dev/loop0", Mode: 0660, Devmajor: 7},
+		{Typeflag: tar.TypeFifo, Name: "run/pipe", Mode: 0600},
+	} {
+		if err := ta.WriteHeader(h); err != nil {
+			t.Fatalf("failed to write header %s: %v", h.Name, err)
+		}
+	}
+	ta.Close()
+
+	r, err := FromTar(b, RPMMetaData{})
+	if err != nil {
+		t.Fatalf("FromTar returned err: %v", err)
+	}
+	if err := r.Write(ioutil.Discard); err != nil {
+		t.Fatalf("r.Write() returned err: %v", err)
+	}
+	if d := cmp.Diff([]uint16{060660, 020620, 010600}, r.filemodes); d != "" {
+		t.Errorf("FromTar filemodes differs (want->got):\n%v", d)
+	}
+	if d := cmp.Diff([]int16{0x0700, 0x0500, 0}, r.filerdevs); d != "" {
+		t.Errorf("FromTar rdevs differs (want->got):\n%v", d)
+	}
+}
+
+func TestFromTarCapabilities(t *testing.T) {
+	// vfs_cap_data revision 2 with cap_net_raw permitted and effective.
+	xattr := []byte{
+		0x01, 0x00, 0x00, 0x02,
+		0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	b := &bytes.Buffer{}
+	ta := tar.NewWriter(b)
+	for _, h := range []*tar.Header{
+		{Typeflag: tar.TypeReg, Name: "usr/bin/agent", Mode: 0755, Format: tar.FormatPAX,
+			PAXRecords: map[string]string{"SCHILY.xattr.security.capability": string(xattr)}},
+		{Typeflag: tar.TypeReg, Name: "usr/bin/other", Mode: 0755},
+	} {
+		if err := ta.WriteHeader(h); err != nil {
+			t.Fatalf("failed to write header %s: %v", h.Name, err)
+		}
+	}
+	ta.Close()
+
+	r, err := FromTar(b, RPMMetaData{})
+	if err != nil {
+		t.Fatalf("FromTar returned err: %v", err)
+	}
+	if err := r.Write(ioutil.Discard); err != nil {
+		t.Fatalf("r.Write() returned err: %v", err)
+	}
+	if d := cmp.Diff([]string{"cap_net_raw=ep", ""}, r.filecaps); d != "" {
+		t.Errorf("FromTar filecaps differs (want->got):\n%v", d)
+	}
+}
+
+func TestFromTarClose(t *testing.T) {
+	// A body larger than the memory limit is spooled to a temporary file.
+	body := make([]byte, spoolMemLimit+1)
+	b := &bytes.Buffer{}
+	ta := tar.NewWriter(b)
+	if err := ta.WriteHeader(&tar.Header{Name: "big", Mode: 0644, Size: int64(len(body))}); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	if _, err := ta.Write(body); err != nil {
+		t.Fatalf("failed to write body: %v", err)
+	}
+	ta.Close()
+
+	r, err := FromTar(b, RPMMetaData{})
+	if err != nil {
+		t.Fatalf("FromTar returned err: %v", err)
+	}
+	if r.bodies.f == nil {
+		t.Fatal("FromTar did not spool the body to a file")
+	}
+	name := r.bodies.f.Name()
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned err: %v", err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("spool file %s still exists after Close", name)
+	}
+}
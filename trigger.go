@@ -0,0 +1,172 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"errors"
+	"fmt"
+)
+
+// triggerTypes are the sense flags telling when a trigger runs.
+const triggerTypes = TriggerPrein | TriggerIn | TriggerUn | TriggerPostun
+
+var (
+	// ErrTriggerType is returned when a trigger has no valid type.
+	ErrTriggerType = errors.New("trigger type must be one of TriggerPrein, TriggerIn, TriggerUn or TriggerPostun")
+	// ErrTriggerNoConditions is returned when a trigger has no conditions.
+	ErrTriggerNoConditions = errors.New("trigger has no conditions")
+)
+
+// Trigger is a scriptlet run when another package matching one of its
+// conditions is installed or removed.
+// https://rpm-software-management.github.io/rpm/manual/triggers.html
+type Trigger struct {
+	// Type is one of TriggerPrein, TriggerIn, TriggerUn or TriggerPostun.
+	Type rpmSense
+	// Conditions are the packages firing the trigger, e.g. `foo >= 1.0`.
+	Conditions Relations
+	// Script is run when the trigger fires. rpm does not pass arguments to
+	// trigger interpreters, so at most one interpreter element is allowed.
+	Script Scriptlet
+}
+
+func (t Trigger) validate() error {
+	switch t.Type {
+	case TriggerPrein, TriggerIn, TriggerUn, TriggerPostun:
+	default:
+		return ErrTriggerType
+	}
+	if len(t.Conditions) == 0 {
+		return ErrTriggerNoConditions
+	}
+	for _, c := range t.Conditions {
+		if c.Sense&^(SenseLess|SenseGreater|SenseEqual) != 0 {
+			return fmt.Errorf("trigger condition %q has unexpected flags %#x", c.Name, uint32(c.Sense))
+		}
+	}
+	if len(t.Script.Interpreter) > 1 {
+		return fmt.Errorf("trigger interpreter %q can't take arguments", t.Script.Interpreter)
+	}
+	return nil
+}
+
+// triggerTags are the tags of a family of triggers.
+type triggerTags struct {
+	scripts, progs, names, versions, flags, indexes int
+}
+
+var triggerTagSet = triggerTags{
+	scripts:  tagTriggerScripts,
+	progs:    tagTriggerScriptProg,
+	names:    tagTriggerName,
+	versions: tagTriggerVersion,
+	flags:    tagTriggerFlags,
+	indexes:  tagTriggerIndex,
+}
+
+// triggers holds the triggers of a package, in the order they were added.
+type triggers []Trigger
+
+// AddToIndex adds the triggers to the index. Conditions of all the triggers
+// are flattened in the name, version and flags tags, and the index tag points
+// each condition to its script.
+func (t triggers) AddToIndex(h *index, tags triggerTags) {
+	if len(t) == 0 {
+		return
+	}
+	var (
+		names    []string
+		versions []string
+		flags    []uint32
+		indexes  []int32
+		scripts  = make([]string, len(t))
+		progs    = make([]string, len(t))
+	)
+	for i, trigger := range t {
+		for _, c := range trigger.Conditions {
+			names = append(names, c.Name)
+			versions = append(versions, c.Version)
+			flags = append(flags, uint32(c.Sense|trigger.Type))
+			indexes = append(indexes, int32(i))
+		}
+		scripts[i] = trigger.Script.Body
+		progs[i] = BIN_SH
+		if len(trigger.Script.Interpreter) > 0 {
+			progs[i] = trigger.Script.Interpreter[0]
+		}
+	}
+	h.Add(tags.names, EntryStringSlice(names))
+	h.Add(tags.versions, EntryStringSlice(versions))
+	h.Add(tags.flags, EntryUint32(flags))
+	h.Add(tags.indexes, EntryInt32(indexes))
+	h.Add(tags.scripts, EntryStringSlice(scripts))
+	h.Add(tags.progs, EntryStringSlice(progs))
+}
+
+// scriptlets returns the scripts of all the triggers.
+func (t triggers) scriptlets() []Scriptlet {
+	s := make([]Scriptlet, len(t))
+	for i, trigger := range t {
+		s[i] = trigger.Script
+	}
+	return s
+}
+
+// AddTrigger adds a trigger scriptlet to the package. A package can have
+// several triggers, and each trigger can have several conditions.
+func (r *RPM) AddTrigger(t Trigger) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	r.triggers = append(r.triggers, t)
+	return nil
+}
+
+// readTriggers rebuilds a family of triggers from the header.
+func readTriggers(h *index, tags triggerTags) (triggers, error) {
+	names, _ := popTag(h.entries, tags.names, IndexEntry.toStringArray)
+	versions, _ := popTag(h.entries, tags.versions, IndexEntry.toStringArray)
+	flags, _ := popTag(h.entries, tags.flags, IndexEntry.toInt32Array)
+	indexes, _ := popTag(h.entries, tags.indexes, IndexEntry.toInt32Array)
+	scripts, _ := popTag(h.entries, tags.scripts, IndexEntry.toStringArray)
+	progs, _ := popTag(h.entries, tags.progs, IndexEntry.toStringArray)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if len(names) != len(versions) || len(names) != len(flags) || len(names) != len(indexes) {
+		return nil, fmt.Errorf("mismatch in trigger condition counts %d %d %d %d", len(names), len(versions), len(flags), len(indexes))
+	}
+	if len(scripts) != len(progs) {
+		return nil, fmt.Errorf("mismatch in trigger script counts %d %d", len(scripts), len(progs))
+	}
+	out := make(triggers, len(scripts))
+	for i, s := range scripts {
+		out[i].Script = Scriptlet{Body: s, Interpreter: []string{progs[i]}}
+	}
+	for i, name := range names {
+		idx := indexes[i]
+		if idx < 0 || int(idx) >= len(scripts) {
+			return nil, fmt.Errorf("trigger condition %q points to missing script %d", name, idx)
+		}
+		sense := rpmSense(flags[i])
+		out[idx].Type = sense & triggerTypes
+		out[idx].Conditions = append(out[idx].Conditions, &Relation{
+			Name:    name,
+			Version: versions[i],
+			Sense:   sense &^ triggerTypes,
+		})
+	}
+	return out, nil
+}
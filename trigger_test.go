@@ -0,0 +1,159 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAddTriggerValidation(t *testing.T) {
+	foo := Relations{{Name: "foo"}}
+	testCases := []struct {
+		name    string
+		trigger Trigger
+		wantErr bool
+		wantIs  error
+	}{{
+		name:    "valid",
+		trigger: Trigger{Type: TriggerIn, Conditions: foo, Script: NewScriptlet("echo hi")},
+	}, {
+		name:    "missing type",
+		trigger: Trigger{Conditions: foo},
+		wantErr: true,
+		wantIs:  ErrTriggerType,
+	}, {
+		name:    "several types",
+		trigger: Trigger{Type: TriggerIn | TriggerUn, Conditions: foo},
+		wantErr: true,
+		wantIs:  ErrTriggerType,
+	}, {
+		name:    "no conditions",
+		trigger: Trigger{Type: TriggerPostun},
+		wantErr: true,
+		wantIs:  ErrTriggerNoConditions,
+	}, {
+		name: "interpreter arguments",
+		trigger: Trigger{
+			Type:       TriggerIn,
+			Conditions: foo,
+			Script:     NewScriptlet("echo hi", "/bin/bash", "-e"),
+		},
+		wantErr: true,
+	}, {
+		name: "condition with trigger flags",
+		trigger: Trigger{
+			Type:       TriggerIn,
+			Conditions: Relations{{Name: "foo", Sense: TriggerUn}},
+		},
+		wantErr: true,
+	}}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRPM(RPMMetaData{})
+			if err != nil {
+				t.Fatalf("NewRPM returned error %v", err)
+			}
+			err = r.AddTrigger(tc.trigger)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("AddTrigger returned error %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantIs != nil && !errors.Is(err, tc.wantIs) {
+				t.Errorf("AddTrigger returned error %v, want %v", err, tc.wantIs)
+			}
+		})
+	}
+}
+
+func TestTriggersIndex(t *testing.T) {
+	tr := triggers{{
+		Type:       TriggerIn,
+		Conditions: Relations{{Name: "foo"}, {Name: "bar", Version: "1.0", Sense: SenseGreater | SenseEqual}},
+		Script:     NewScriptlet("echo in"),
+	}, {
+		Type:       TriggerPostun,
+		Conditions: Relations{{Name: "baz", Version: "2", Sense: SenseLess}},
+		Script:     NewScriptlet("print('postun')", LuaInterpreter),
+	}}
+	h := newIndex(immutable)
+	tr.AddToIndex(h, triggerTagSet)
+
+	want := map[int]IndexEntry{
+		tagTriggerName:       EntryStringSlice([]string{"foo", "bar", "baz"}),
+		tagTriggerVersion:    EntryStringSlice([]string{"", "1.0", "2"}),
+		tagTriggerFlags:      EntryUint32([]uint32{uint32(TriggerIn), uint32(TriggerIn | SenseGreater | SenseEqual), uint32(TriggerPostun | SenseLess)}),
+		tagTriggerIndex:      EntryInt32([]int32{0, 0, 1}),
+		tagTriggerScripts:    EntryStringSlice([]string{"echo in", "print('postun')"}),
+		tagTriggerScriptProg: EntryStringSlice([]string{"/bin/sh", "<lua>"}),
+	}
+	if d := cmp.Diff(want, h.entries, cmp.AllowUnexported(IndexEntry{})); d != "" {
+		t.Errorf("index differs (want->got):\n%v", d)
+	}
+}
+
+func TestTriggersRoundTrip(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "triggers"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	want := triggers{{
+		Type:       TriggerIn,
+		Conditions: Relations{{Name: "httpd"}, {Name: "nginx", Version: "1.20", Sense: SenseGreater | SenseEqual}},
+		Script:     Scriptlet{Body: "systemctl reload config-watcher", Interpreter: []string{"/bin/sh"}},
+	}, {
+		Type:       TriggerPrein,
+		Conditions: Relations{{Name: "httpd", Version: "2.4", Sense: SenseLess}},
+		Script:     Scriptlet{Body: "print('upgrading')", Interpreter: []string{"<lua>"}},
+	}, {
+		Type:       TriggerUn,
+		Conditions: Relations{{Name: "nginx"}},
+		Script:     Scriptlet{Body: "rm -f /etc/config-watcher/nginx", Interpreter: []string{"/bin/sh"}},
+	}}
+	for _, tr := range want {
+		if err := r.AddTrigger(tr); err != nil {
+			t.Fatalf("AddTrigger returned error %v", err)
+		}
+	}
+
+	f, err := os.CreateTemp(t.TempDir(), "triggers-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	lua := &Relation{Name: "rpmlib(BuiltinLuaScripts)", Version: "4.2.2-1", Sense: SenseRPMLIB | SenseLess | SenseEqual}
+	found := false
+	for _, req := range r.Requires {
+		found = found || req.Equal(lua)
+	}
+	if !found {
+		t.Errorf("Requires %v is missing rpmlib(BuiltinLuaScripts)", r.Requires.String())
+	}
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	if d := cmp.Diff(want, got.triggers); d != "" {
+		t.Errorf("triggers differ (want->got):\n%v", d)
+	}
+}
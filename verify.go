@@ -0,0 +1,163 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// VerifyFlags are the attributes of a file checked by `rpm -V`.
+// https://github.com/rpm-software-management/rpm/blob/master/include/rpm/rpmvf.h
+type VerifyFlags uint32
+
+const (
+	// VerifyDigest checks the digest of the file content.
+	VerifyDigest VerifyFlags = 1 << iota
+	// VerifySize checks the file size.
+	VerifySize
+	// VerifyLinkTo checks the target of symlinks.
+	VerifyLinkTo
+	// VerifyUser checks the owner of the file.
+	VerifyUser
+	// VerifyGroup checks the group of the file.
+	VerifyGroup
+	// VerifyMTime checks the modification time.
+	VerifyMTime
+	// VerifyMode checks the permissions and the file type.
+	VerifyMode
+	// VerifyRDev checks the device numbers.
+	VerifyRDev
+	// VerifyCaps checks the file capabilities.
+	VerifyCaps
+
+	// VerifyAll checks everything, it is what rpm uses by default.
+	VerifyAll VerifyFlags = ^VerifyFlags(0)
+)
+
+// verifyNames are the names of the flags in %verify, as understood by rpmbuild.
+var verifyNames = map[string]VerifyFlags{
+	"md5":        VerifyDigest,
+	"filedigest": VerifyDigest,
+	"size":       VerifySize,
+	"link":       VerifyLinkTo,
+	"user":       VerifyUser,
+	"owner":      VerifyUser,
+	"group":      VerifyGroup,
+	"mtime":      VerifyMTime,
+	"mode":       VerifyMode,
+	"rdev":       VerifyRDev,
+	"caps":       VerifyCaps,
+}
+
+// ParseNoVerify parses the arguments of a %verify directive and returns the
+// checks to skip, suitable for RPMFile.NoVerify. `not md5 size mtime` skips
+// the listed checks, while `mode user group` only keeps the listed checks.
+func ParseNoVerify(s string) (VerifyFlags, error) {
+	fields := strings.Fields(s)
+	not := len(fields) > 0 && fields[0] == "not"
+	if not {
+		fields = fields[1:]
+	}
+	var flags VerifyFlags
+	for _, name := range fields {
+		f, ok := verifyNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown verify flag %q", name)
+		}
+		flags |= f
+	}
+	if not {
+		return flags, nil
+	}
+	return VerifyAll &^ flags, nil
+}
+
+// String returns the flags in the %verify syntax.
+func (v VerifyFlags) String() string {
+	var names []string
+	for name, f := range verifyNames {
+		// Only use the names rpm -V prints.
+		if v&f != 0 && name != "md5" && name != "owner" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+// VerifyRule sets the checks skipped by `rpm -V` on the files matching a glob.
+type VerifyRule struct {
+	// Pattern is matched against the full file name with path.Match, e.g.
+	// `/var/log/myapp/*`.
+	Pattern string
+	// NoVerify are the checks to skip on matching files.
+	NoVerify VerifyFlags
+}
+
+// VerifyRules is a list of VerifyRule. When several rules match a file, the
+// last one wins.
+type VerifyRules []VerifyRule
+
+// String returns the string representation of the rules.
+func (v *VerifyRules) String() string {
+	var val []string
+	for _, rule := range *v {
+		val = append(val, fmt.Sprintf("%s=not %v", rule.Pattern, rule.NoVerify))
+	}
+	return strings.Join(val, ",")
+}
+
+// Set parses a rule of the form `GLOB=VERIFY`, e.g. `/var/log/*=not md5 size
+// mtime`, and appends it to the rules. This is used by the flag package.
+func (v *VerifyRules) Set(value string) error {
+	pattern, spec, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("verify rule %q is not of the form GLOB=VERIFY", value)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid verify rule pattern %q: %w", pattern, err)
+	}
+	noVerify, err := ParseNoVerify(spec)
+	if err != nil {
+		return err
+	}
+	*v = append(*v, VerifyRule{Pattern: pattern, NoVerify: noVerify})
+	return nil
+}
+
+// ApplyVerifyRules sets NoVerify on all the files of the package matching one
+// of the rules.
+func (r *RPM) ApplyVerifyRules(rules VerifyRules) error {
+	for fn, f := range r.files {
+		matched := false
+		for _, rule := range rules {
+			ok, err := path.Match(rule.Pattern, fn)
+			if err != nil {
+				return fmt.Errorf("invalid verify rule pattern %q: %w", rule.Pattern, err)
+			}
+			if ok {
+				f.NoVerify = rule.NoVerify
+				matched = true
+			}
+		}
+		if matched {
+			r.files[fn] = f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseNoVerify(t *testing.T) {
+	testCases := []struct {
+		spec    string
+		want    VerifyFlags
+		wantErr bool
+	}{{
+		spec: "not md5 size mtime",
+		want: VerifyDigest | VerifySize | VerifyMTime,
+	}, {
+		spec: "not filedigest owner",
+		want: VerifyDigest | VerifyUser,
+	}, {
+		spec: "mode user group",
+		want: VerifyAll &^ (VerifyMode | VerifyUser | VerifyGroup),
+	}, {
+		spec: "",
+		want: VerifyAll,
+	}, {
+		spec: "not",
+		want: 0,
+	}, {
+		spec:    "not md5 checksum",
+		wantErr: true,
+	}}
+	for _, tc := range testCases {
+		got, err := ParseNoVerify(tc.spec)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseNoVerify(%q) returned error %v, want error %v", tc.spec, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseNoVerify(%q) = %#x, want %#x", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestVerifyRules(t *testing.T) {
+	var rules VerifyRules
+	for _, v := range []string{
+		"/var/log/myapp/*=not md5 size mtime",
+		"/etc/myapp/*.conf=not mtime",
+		"/etc/myapp/static.conf=not",
+	} {
+		if err := rules.Set(v); err != nil {
+			t.Fatalf("Set(%q) returned error %v", v, err)
+		}
+	}
+	for _, v := range []string{"/etc/myapp", "/etc/[=not md5", "/etc/*=not checksum"} {
+		if err := rules.Set(v); err == nil {
+			t.Errorf("Set(%q) returned no error", v)
+		}
+	}
+
+	r, err := NewRPM(RPMMetaData{})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	for _, name := range []string{
+		"/var/log/myapp/app.log",
+		"/var/log/myapp/old/app.log",
+		"/etc/myapp/app.conf",
+		"/etc/myapp/static.conf",
+		"/usr/bin/myapp",
+	} {
+		r.AddFile(RPMFile{Name: name})
+	}
+	if err := r.ApplyVerifyRules(rules); err != nil {
+		t.Fatalf("ApplyVerifyRules returned error %v", err)
+	}
+	want := map[string]VerifyFlags{
+		"/var/log/myapp/app.log":     VerifyDigest | VerifySize | VerifyMTime,
+		"/var/log/myapp/old/app.log": 0,
+		"/etc/myapp/app.conf":        VerifyMTime,
+		"/etc/myapp/static.conf":     0,
+		"/usr/bin/myapp":             0,
+	}
+	got := map[string]VerifyFlags{}
+	for name, f := range r.files {
+		got[name] = f.NoVerify
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("NoVerify differs (want->got):\n%v", d)
+	}
+}
+
+func TestVerifyRoundTrip(t *testing.T) {
+	r, err := NewRPM(RPMMetaData{Name: "verify"})
+	if err != nil {
+		t.Fatalf("NewRPM returned error %v", err)
+	}
+	r.AddFile(RPMFile{Name: "/usr/bin/myapp", Body: []byte("binary")})
+	r.AddFile(RPMFile{Name: "/var/log/myapp.log", Type: GhostFile, NoVerify: VerifyDigest | VerifySize | VerifyMTime})
+	r.AddVerifyScript("test -d /var/lib/myapp", "/bin/bash")
+	r.AllowListDirs(map[string]bool{})
+
+	f, err := os.CreateTemp(t.TempDir(), "verify-*.rpm")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	if err := r.Write(f); err != nil {
+		t.Fatalf("Write returned error %v", err)
+	}
+	f.Close()
+
+	wantFlags := []uint32{uint32(VerifyAll), uint32(VerifyAll &^ (VerifyDigest | VerifySize | VerifyMTime))}
+	if d := cmp.Diff(wantFlags, r.fileverifyflags); d != "" {
+		t.Errorf("file verify flags differ (want->got):\n%v", d)
+	}
+
+	got, err := ReadRPMFile(f.Name())
+	if err != nil {
+		t.Fatalf("ReadRPMFile returned error %v", err)
+	}
+	if d := cmp.Diff(wantFlags, got.fileverifyflags); d != "" {
+		t.Errorf("read file verify flags differ (want->got):\n%v", d)
+	}
+	if nv := got.files["/usr/bin/myapp"].NoVerify; nv != 0 {
+		t.Errorf("/usr/bin/myapp NoVerify = %#x, want 0", nv)
+	}
+	want := Scriptlet{Body: "test -d /var/lib/myapp", Interpreter: []string{"/bin/bash"}}
+	if d := cmp.Diff(want, got.verifyscript); d != "" {
+		t.Errorf("verify script differs (want->got):\n%v", d)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isAlpha(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+// rpmvercmp compares two versions or releases like rpm does, returning -1, 0
+// or 1. Versions are split into numeric and alphabetic segments, other
+// characters only separate them. `~` sorts before anything, even the end of
+// the version, and `^` sorts after the end of the version but before
+// anything else.
+// https://github.com/rpm-software-management/rpm/blob/master/rpmio/rpmvercmp.c
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isDigit(a[i]) && !isAlpha(a[i]) && a[i] != '~' && a[i] != '^' {
+			i++
+		}
+		for j < len(b) && !isDigit(b[j]) && !isAlpha(b[j]) && b[j] != '~' && b[j] != '^' {
+			j++
+		}
+
+		// Tilde sorts before everything else.
+		if i < len(a) && a[i] == '~' || j < len(b) && b[j] == '~' {
+			if i >= len(a) || a[i] != '~' {
+				return 1
+			}
+			if j >= len(b) || b[j] != '~' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+
+		// Caret sorts after the end of the version, but before anything else.
+		if i < len(a) && a[i] == '^' || j < len(b) && b[j] == '^' {
+			if i >= len(a) {
+				return -1
+			}
+			if j >= len(b) {
+				return 1
+			}
+			if a[i] != '^' {
+				return 1
+			}
+			if b[j] != '^' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+
+		if i >= len(a) || j >= len(b) {
+			break
+		}
+
+		// Take the next segments of the same type.
+		si, sj := i, j
+		isNum := isDigit(a[i])
+		class := isAlpha
+		if isNum {
+			class = isDigit
+		}
+		for si < len(a) && class(a[si]) {
+			si++
+		}
+		for sj < len(b) && class(b[sj]) {
+			sj++
+		}
+		segA, segB := a[i:si], b[j:sj]
+		if segB == "" {
+			// Numeric segments are newer than alphabetic ones.
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+		if isNum {
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				if len(segA) > len(segB) {
+					return 1
+				}
+				return -1
+			}
+		}
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+		i, j = si, sj
+	}
+	switch {
+	case i >= len(a) && j >= len(b):
+		return 0
+	case i >= len(a):
+		return -1
+	}
+	return 1
+}
+
+// EVR is the epoch, version and release of a package, as in
+// `epoch:version-release`.
+type EVR struct {
+	Epoch   uint32
+	Version string
+	Release string
+}
+
+// ParseEVR parses `[epoch:]version[-release]`. The release is everything
+// after the last `-`.
+func ParseEVR(s string) (EVR, error) {
+	var e EVR
+	if epoch, rest, ok := strings.Cut(s, ":"); ok {
+		n, err := strconv.ParseUint(epoch, 10, 32)
+		if err != nil {
+			return EVR{}, fmt.Errorf("invalid epoch in %q", s)
+		}
+		e.Epoch, s = uint32(n), rest
+	}
+	e.Version = s
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		e.Version, e.Release = s[:i], s[i+1:]
+	}
+	if e.Version == "" {
+		return EVR{}, fmt.Errorf("missing version in %q", s)
+	}
+	return e, nil
+}
+
+// String returns the EVR as `[epoch:]version[-release]`, leaving out a zero
+// epoch and an empty release.
+func (e EVR) String() string {
+	s := e.Version
+	if e.Epoch != 0 {
+		s = strconv.FormatUint(uint64(e.Epoch), 10) + ":" + s
+	}
+	if e.Release != "" {
+		s += "-" + e.Release
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 when e is older, the same or newer than o, like
+// rpm orders packages.
+func (e EVR) Compare(o EVR) int {
+	switch {
+	case e.Epoch < o.Epoch:
+		return -1
+	case e.Epoch > o.Epoch:
+		return 1
+	}
+	if c := rpmvercmp(e.Version, o.Version); c != 0 {
+		return c
+	}
+	return rpmvercmp(e.Release, o.Release)
+}
+
+// compareDep compares like Compare, except that a release missing on either
+// side matches any release, as `foo >= 1.0` is satisfied by `foo-1.0-3`.
+func (e EVR) compareDep(o EVR) int {
+	if e.Release == "" || o.Release == "" {
+		e.Release, o.Release = "", ""
+	}
+	return e.Compare(o)
+}
+
+// SatisfiedBy reports whether a package at version e satisfies the version
+// constraint of the relation. The name is not compared, and unversioned
+// relations are satisfied by any version.
+func (r *Relation) SatisfiedBy(e EVR) bool {
+	if r.Version == "" {
+		return true
+	}
+	want, err := ParseEVR(r.Version)
+	if err != nil {
+		return false
+	}
+	switch c := e.compareDep(want); {
+	case c < 0:
+		return r.Sense&SenseLess != 0
+	case c > 0:
+		return r.Sense&SenseGreater != 0
+	}
+	return r.Sense&SenseEqual != 0
+}
+
+// overlaps reports whether the version ranges of two relations on the same
+// name intersect, like rpmdsCompare. Unversioned relations match any
+// version.
+func (r *Relation) overlaps(o *Relation) bool {
+	if r.Name != o.Name {
+		return false
+	}
+	if r.Version == "" || o.Version == "" {
+		return true
+	}
+	a, errA := ParseEVR(r.Version)
+	b, errB := ParseEVR(o.Version)
+	if errA != nil || errB != nil {
+		return false
+	}
+	switch c := a.compareDep(b); {
+	case c < 0:
+		return r.Sense&SenseGreater != 0 || o.Sense&SenseLess != 0
+	case c > 0:
+		return r.Sense&SenseLess != 0 || o.Sense&SenseGreater != 0
+	}
+	return r.Sense&o.Sense&senseCompare != 0
+}
+
+// EVR returns the epoch, version and release of the package.
+func (m *RPMMetaData) EVR() EVR {
+	return EVR{Epoch: m.Epoch, Version: m.Version, Release: m.Release}
+}
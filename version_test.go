@@ -0,0 +1,219 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rpmpack
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestRpmvercmp uses the table of rpm's own test suite.
+// https://github.com/rpm-software-management/rpm/blob/master/tests/rpmvercmp.at
+func TestRpmvercmp(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0", "1.0", 1},
+		{"2.0.1", "2.0.1", 0},
+		{"2.0", "2.0.1", -1},
+		{"2.0.1", "2.0", 1},
+		{"2.0.1a", "2.0.1a", 0},
+		{"2.0.1a", "2.0.1", 1},
+		{"2.0.1", "2.0.1a", -1},
+		{"5.5p1", "5.5p1", 0},
+		{"5.5p1", "5.5p2", -1},
+		{"5.5p2", "5.5p1", 1},
+		{"5.5p10", "5.5p10", 0},
+		{"5.5p1", "5.5p10", -1},
+		{"5.5p10", "5.5p1", 1},
+		{"10xyz", "10.1xyz", -1},
+		{"10.1xyz", "10xyz", 1},
+		{"xyz10", "xyz10", 0},
+		{"xyz10", "xyz10.1", -1},
+		{"xyz10.1", "xyz10", 1},
+		{"xyz.4", "xyz.4", 0},
+		{"xyz.4", "8", -1},
+		{"8", "xyz.4", 1},
+		{"xyz.4", "2", -1},
+		{"2", "xyz.4", 1},
+		{"5.5p2", "5.6p1", -1},
+		{"5.6p1", "5.5p2", 1},
+		{"5.6p1", "6.5p1", -1},
+		{"6.5p1", "5.6p1", 1},
+		{"6.0.rc1", "6.0", 1},
+		{"6.0", "6.0.rc1", -1},
+		{"10b2", "10a1", 1},
+		{"10a2", "10b2", -1},
+		{"1.0aa", "1.0aa", 0},
+		{"1.0a", "1.0aa", -1},
+		{"1.0aa", "1.0a", 1},
+		{"10.0001", "10.0001", 0},
+		{"10.0001", "10.1", 0},
+		{"10.1", "10.0001", 0},
+		{"10.0001", "10.0039", -1},
+		{"10.0039", "10.0001", 1},
+		{"4.999.9", "5.0", -1},
+		{"5.0", "4.999.9", 1},
+		{"20101121", "20101121", 0},
+		{"20101121", "20101122", -1},
+		{"20101122", "20101121", 1},
+		{"2_0", "2_0", 0},
+		{"2.0", "2_0", 0},
+		{"2_0", "2.0", 0},
+		{"a", "a", 0},
+		{"a+", "a+", 0},
+		{"a+", "a_", 0},
+		{"a_", "a+", 0},
+		{"+a", "+a", 0},
+		{"+a", "_a", 0},
+		{"_a", "+a", 0},
+		{"+_", "+_", 0},
+		{"_+", "+_", 0},
+		{"_+", "_+", 0},
+		{"+", "_", 0},
+		{"_", "+", 0},
+		{"1.0~rc1", "1.0~rc1", 0},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0~rc1", 1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~rc2", "1.0~rc1", 1},
+		{"1.0~rc1~git123", "1.0~rc1~git123", 0},
+		{"1.0~rc1~git123", "1.0~rc1", -1},
+		{"1.0~rc1", "1.0~rc1~git123", 1},
+		{"1.0^", "1.0^", 0},
+		{"1.0^", "1.0", 1},
+		{"1.0", "1.0^", -1},
+		{"1.0^git1", "1.0^git1", 0},
+		{"1.0^git1", "1.0", 1},
+		{"1.0", "1.0^git1", -1},
+		{"1.0^git1", "1.0^git2", -1},
+		{"1.0^git2", "1.0^git1", 1},
+		{"1.0^git1", "1.01", -1},
+		{"1.01", "1.0^git1", 1},
+		{"1.0^20160101", "1.0^20160101", 0},
+		{"1.0^20160101", "1.0.1", -1},
+		{"1.0.1", "1.0^20160101", 1},
+		{"1.0^20160101^git1", "1.0^20160101^git1", 0},
+		{"1.0^20160102", "1.0^20160101^git1", 1},
+		{"1.0^20160101^git1", "1.0^20160102", -1},
+		{"1.0~rc1^git1", "1.0~rc1^git1", 0},
+		{"1.0~rc1^git1", "1.0~rc1", 1},
+		{"1.0~rc1", "1.0~rc1^git1", -1},
+		{"1.0^git1~pre", "1.0^git1~pre", 0},
+		{"1.0^git1", "1.0^git1~pre", 1},
+		{"1.0^git1~pre", "1.0^git1", -1},
+		{"1b.fc17", "1b.fc17", 0},
+		{"1b.fc17", "1.fc17", -1},
+		{"1.fc17", "1b.fc17", 1},
+		{"1g.fc17", "1g.fc17", 0},
+		{"1g.fc17", "1.fc17", 1},
+		{"1.fc17", "1g.fc17", -1},
+	}
+	for _, tc := range testCases {
+		if got := rpmvercmp(tc.a, tc.b); got != tc.want {
+			t.Errorf("rpmvercmp(%q, %q) want %d, got %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
+
+func TestParseEVR(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    EVR
+		wantErr bool
+	}{
+		{input: "1.0", want: EVR{Version: "1.0"}},
+		{input: "1.0-3.el9", want: EVR{Version: "1.0", Release: "3.el9"}},
+		{input: "2:1.10~rc1-1", want: EVR{Epoch: 2, Version: "1.10~rc1", Release: "1"}},
+		{input: "x:1.0", wantErr: true},
+		{input: "1:", wantErr: true},
+		{input: "-1", wantErr: true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseEVR(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ParseEVR(%q) returned no error", tc.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseEVR(%q) returned err: %v", tc.input, err)
+			}
+			if d := cmp.Diff(tc.want, got); d != "" {
+				t.Errorf("ParseEVR(%q) differs (want->got):\n%v", tc.input, d)
+			}
+			if got.String() != tc.input {
+				t.Errorf("ParseEVR(%q).String() = %q", tc.input, got.String())
+			}
+		})
+	}
+}
+
+func TestEVRCompare(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.10~rc1", "1.10", -1},
+		{"1.10", "1.9", 1},
+		{"1:1.0", "2.0", 1},
+		{"1.0-1", "1.0-2", -1},
+		{"1.0", "1.0-1", -1},
+		{"0:1.0-1", "1.0-1", 0},
+	}
+	for _, tc := range testCases {
+		a, _ := ParseEVR(tc.a)
+		b, _ := ParseEVR(tc.b)
+		if got := a.Compare(b); got != tc.want {
+			t.Errorf("%q.Compare(%q) want %d, got %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
+
+func TestRelationSatisfiedBy(t *testing.T) {
+	testCases := []struct {
+		relation, evr string
+		want          bool
+	}{
+		{"foo", "1.0", true},
+		{"foo >= 1.10", "1.10~rc1-1", false},
+		{"foo >= 1.10~rc1", "1.10-1", true},
+		{"foo = 1.0", "1.0-5", true},
+		{"foo = 1.0-4", "1.0-5", false},
+		{"foo < 1.0-4", "1.0-3", true},
+		{"foo > 1.0", "1:0.1", true},
+		{"foo <= 1.0^git1", "1.0.1", false},
+	}
+	for _, tc := range testCases {
+		rel, err := NewRelation(tc.relation)
+		if err != nil {
+			t.Fatalf("NewRelation(%q) returned err: %v", tc.relation, err)
+		}
+		e, err := ParseEVR(tc.evr)
+		if err != nil {
+			t.Fatalf("ParseEVR(%q) returned err: %v", tc.evr, err)
+		}
+		if got := rel.SatisfiedBy(e); got != tc.want {
+			t.Errorf("%q.SatisfiedBy(%q) want %v, got %v", tc.relation, tc.evr, tc.want, got)
+		}
+	}
+}